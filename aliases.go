@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nvlled/gost/genv"
+	"github.com/nvlled/gost/util"
+	"html"
+	fpath "path/filepath"
+	"sort"
+	"strings"
+)
+
+// The aliases entry of a page lists its old paths:
+//
+//   aliases: /old/about.html /about-us/
+//
+// A redirect page to the page is built at each alias. Aliases
+// without an extension are directories, as with pretty-urls.
+// If the base-env has a redirects-file entry, the aliases are
+// also written in that file, relative to destDir, for servers
+// that support redirects:
+//
+//   /old/about.html /about.html 301
+
+const redirectTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%[1]s</title>
+<link rel="canonical" href="%[1]s">
+<meta http-equiv="refresh" content="0; url=%[1]s">
+</head>
+<body>
+<p>This page has moved to <a href="%[1]s">%[1]s</a>.</p>
+</body>
+</html>
+`
+
+type alias struct {
+	path string // e.g. /old/about.html
+	env  genv.T // env of the page
+}
+
+func aliasPath(path string) string {
+	path = fpath.Join("/", path)
+	if fpath.Ext(path) == "" {
+		path = util.AddTrailingSlash(path)
+	}
+	return path
+}
+
+// returns the aliases of the pages, sorted by path.
+// Aliases that collide with a page or with
+// another alias are errors.
+func pageAliases() ([]alias, error) {
+	var srcPaths []string
+	for srcPath := range pathIndex {
+		srcPaths = append(srcPaths, srcPath)
+	}
+	sort.Strings(srcPaths)
+
+	pages := make(map[string]string)
+	for _, srcPath := range srcPaths {
+		pages[pageFile(pathIndex[srcPath].Get("path"))] = srcPath
+	}
+	var aliases []alias
+	owners := make(map[string]string)
+	for _, srcPath := range srcPaths {
+		env := pathIndex[srcPath]
+		for _, path := range strings.Fields(env.Get(aliasesKey)) {
+			path = aliasPath(path)
+			file := pageFile(path)
+			if other, ok := pages[file]; ok {
+				return nil, fmt.Errorf("alias %s of %s collides with %s", path, srcPath, other)
+			}
+			if other, ok := owners[file]; ok {
+				return nil, fmt.Errorf("alias %s of %s is also an alias of %s", path, srcPath, other)
+			}
+			owners[file] = srcPath
+			aliases = append(aliases, alias{path, env})
+		}
+	}
+	return aliases, nil
+}
+
+// returns the url the alias redirects to, which
+// is relative only if the page has relative urls
+// and the base-env has no base-url
+func (a alias) target(state *gostState) (string, error) {
+	path := a.env.Get("path")
+	base, err := parseBaseURL(state.baseEnv.Get(baseURLKey))
+	if err != nil {
+		return "", err
+	}
+	if base.url != nil {
+		return base.absolute(path, path)
+	}
+	if isUrlRelative(a.env) {
+		return util.RelativizePath(a.path, path), nil
+	}
+	return path, nil
+}
+
+func writeAliases(state *gostState, aliases []alias) error {
+	out := state.out
+	for _, a := range aliases {
+		target, err := a.target(state)
+		if err != nil {
+			return err
+		}
+		destPath := fpath.Join(state.destDir, pageFile(a.path))
+		// collisions with the pages are found before the build,
+		// the other files are only known once they are written
+		if out.written[fpath.Clean(destPath)] {
+			return fmt.Errorf("alias %s of %s collides with a file of the build", a.path, srcPaths[a.env.Get("path")])
+		}
+		out.mkdir(fpath.Dir(destPath))
+		printLog("redirecting", a.path, "->", a.env.Get("path"))
+		s := fmt.Sprintf(redirectTemplate, html.EscapeString(target))
+		if err := out.writeFile(destPath, []byte(s)); err != nil {
+			return err
+		}
+	}
+
+	filename := state.baseEnv.Get(redirectsFileKey)
+	if filename == "" {
+		return nil
+	}
+	base, err := parseBaseURL(state.baseEnv.Get(baseURLKey))
+	if err != nil {
+		return err
+	}
+	var data []byte
+	for _, a := range aliases {
+		line := base.withPrefix(a.path) + " " + base.withPrefix(a.env.Get("path")) + " 301\n"
+		data = append(data, line...)
+	}
+	printLog("writing redirects", filename)
+	return out.writeFile(fpath.Join(state.destDir, filename), data)
+}
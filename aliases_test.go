@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	"testing"
+)
+
+func TestPageAliases(t *testing.T) {
+	testData := []struct {
+		envs  []string
+		valid bool
+	}{
+		{[]string{"path: /a.html\naliases: /old/a.html /b", "path: /c.html"}, true},
+		{[]string{"path: /a.html\naliases: /c.html", "path: /c.html"}, false},
+		{[]string{"path: /a.html\naliases: /x/", "path: /x/index.html"}, false},
+		{[]string{"path: /a.html\naliases: /x", "path: /c.html\naliases: /x/"}, false},
+	}
+	for _, row := range testData {
+		resetIndex()
+		for i, s := range row.envs {
+			pathIndex[string('a'+rune(i))] = genv.Parse(s)
+		}
+		aliases, err := pageAliases()
+		if row.valid && err != nil {
+			t.Error(row.envs, "| Expected no error, got", err)
+		} else if !row.valid && err == nil {
+			t.Error(row.envs, "| Expected an error, got", aliases)
+		}
+	}
+	resetIndex()
+}
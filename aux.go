@@ -2,9 +2,12 @@ package main
 
 import (
 	"fmt"
+	"github.com/nvlled/gost/genv"
 	"github.com/nvlled/gost/util"
 	"os"
 	fpath "path/filepath"
+	"strings"
+	"sync"
 	"text/template"
 )
 
@@ -42,6 +45,31 @@ func catchError() {
 	}
 }
 
+// same as catchError, but also stores the error in errp
+func catchErrorAs(errp *error) {
+	err := recover()
+	if err != nil {
+		fmt.Printf("*** error %v\n", err)
+		*errp = fmt.Errorf("%v", err)
+	}
+}
+
+// returns the *genv.CycleError of the entries of env, if any,
+// which are otherwise reported with a panic when they are used
+func envCycleError(env genv.T) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			cycleErr, ok := e.(*genv.CycleError)
+			if !ok {
+				panic(e)
+			}
+			err = cycleErr
+		}
+	}()
+	env.Entries()
+	return nil
+}
+
 func isItemplate(path string) bool {
 	ext := fpath.Ext(path)
 	for _, ext_ := range itemplates {
@@ -51,3 +79,50 @@ func isItemplate(path string) bool {
 	}
 	return false
 }
+
+// converts a path in the srcDir into a path
+// rooted at srcDir, e.g. src/a/b.html -> /a/b.html
+func srcRelativePath(state *gostState, path string) string {
+	if abs, err := fpath.Abs(path); err == nil && strings.HasPrefix(abs, state.srcDir) {
+		path = strings.TrimPrefix(abs, state.srcDir)
+	}
+	return fpath.Join("/", path)
+}
+
+// jobQueue runs functions in the background,
+// at most n at the same time
+type jobQueue struct {
+	slots chan bool
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	err   error
+}
+
+func newJobQueue(n int) *jobQueue {
+	return &jobQueue{slots: make(chan bool, util.Max(n, 1))}
+}
+
+// waits for a free slot and runs job in the background
+func (q *jobQueue) run(job func() error) {
+	q.slots <- true
+	q.wg.Add(1)
+	go func() {
+		defer func() {
+			<-q.slots
+			q.wg.Done()
+		}()
+		if err := job(); err != nil {
+			q.mu.Lock()
+			if q.err == nil {
+				q.err = err
+			}
+			q.mu.Unlock()
+		}
+	}()
+}
+
+// waits for all the jobs and returns the first error
+func (q *jobQueue) wait() error {
+	q.wg.Wait()
+	return q.err
+}
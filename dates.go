@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The time of the build, returned by now and date.
+// It is fixed by the -build-time option or by SOURCE_DATE_EPOCH
+// so that the same source always produces the same output.
+var buildTime = time.Now().Round(0)
+
+// dates without a time zone are in this location,
+// set by the time-zone entry in the base-env
+var timeZone = time.Local
+
+const sourceDateEpochVar = "SOURCE_DATE_EPOCH"
+
+// sets the buildTime and timeZone, buildTimeOpt is either
+// a unix timestamp or a date accepted by parseDate
+func initTime(zone, buildTimeOpt string) error {
+	if zone != "" {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", timeZoneKey, err)
+		}
+		timeZone = loc
+	}
+	s, source := buildTimeOpt, "-build-time"
+	if s == "" {
+		s, source = os.Getenv(sourceDateEpochVar), sourceDateEpochVar
+	}
+	if s == "" {
+		return nil
+	}
+	t, err := toTime(s)
+	if err != nil {
+		return fmt.Errorf("invalid build time in %s: %s", source, s)
+	}
+	buildTime = t
+	return nil
+}
+
+var dateFuncMap = template.FuncMap{
+	"now":         func() time.Time { return buildTime.In(timeZone) },
+	"date_format": dateFormat,
+	"parse_date":  parseDateArgs,
+	"in_zone":     inZone,
+	"add_date":    addDate,
+	"time_ago":    timeAgo,
+}
+
+// layouts that can be used by name, such as {{date_format "rfc3339" .date}}
+var namedLayouts = map[string]string{
+	"ansic":    time.ANSIC,
+	"rfc822":   time.RFC822,
+	"rfc822z":  time.RFC822Z,
+	"rfc1123":  time.RFC1123,
+	"rfc1123z": time.RFC1123Z,
+	"rfc3339":  time.RFC3339,
+	"kitchen":  time.Kitchen,
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04:05",
+}
+
+// layouts tried by parse_date when no layout is given
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 02 Jan 2006 MST",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"02 Jan 2006",
+}
+
+var strftimeDirectives = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'p': "PM",
+	'b': "Jan", 'h': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'Z': "MST", 'z': "-0700", 'j': "002",
+	'F': "2006-01-02", 'T': "15:04:05", 'D': "01/02/06", 'R': "15:04",
+	'%': "%",
+}
+
+// without padding, as in %-d
+var strftimeUnpadded = map[byte]string{
+	'm': "1", 'd': "2", 'I': "3", 'H': "15", 'M': "4", 'S': "5",
+}
+
+// converts a layout name or a strftime layout
+// into a Go layout, Go layouts are returned as is
+func goLayout(layout string) string {
+	if s, ok := namedLayouts[strings.ToLower(layout)]; ok {
+		return s
+	}
+	if !strings.Contains(layout, "%") {
+		return layout
+	}
+	var buf strings.Builder
+	for i := 0; i < len(layout); i++ {
+		c := layout[i]
+		if c != '%' || i+1 >= len(layout) {
+			buf.WriteByte(c)
+			continue
+		}
+		directives := strftimeDirectives
+		next, skip := layout[i+1], 1
+		if next == '-' && i+2 < len(layout) {
+			directives = strftimeUnpadded
+			next, skip = layout[i+2], 2
+		}
+		if s, ok := directives[next]; ok {
+			buf.WriteString(s)
+			i += skip
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+func parseDate(s string, layouts ...string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(layouts) == 0 {
+		layouts = dateLayouts
+	}
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(goLayout(layout), s, timeZone)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid date: " + s)
+}
+
+// parse_date s or parse_date layout s
+func parseDateArgs(args ...string) (time.Time, error) {
+	switch len(args) {
+	case 1:
+		return parseDate(args[0])
+	case 2:
+		return parseDate(args[1], args[0])
+	}
+	return time.Time{}, errors.New("usage: parse_date [layout] date")
+}
+
+// converts dates, strings of dates and unix timestamps into time
+func toTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case int:
+		return time.Unix(int64(t), 0).In(timeZone), nil
+	case int64:
+		return time.Unix(t, 0).In(timeZone), nil
+	}
+	s := toString(v)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0).In(timeZone), nil
+	}
+	return parseDate(s)
+}
+
+func dateFormat(layout string, v interface{}) (string, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(goLayout(layout)), nil
+}
+
+func inZone(zone string, v interface{}) (time.Time, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return t, err
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return t, err
+	}
+	return t.In(loc), nil
+}
+
+func addDate(years, months, days int, v interface{}) (time.Time, error) {
+	t, err := toTime(v)
+	return t.AddDate(years, months, days), err
+}
+
+// describes the time relative to the build time,
+// such as "3 days ago" or "in 2 hours"
+func timeAgo(v interface{}) (string, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return "", err
+	}
+	d := buildTime.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now", nil
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	case d < 30*24*time.Hour:
+		n, unit = int(d/(24*time.Hour)), "day"
+	case d < 365*24*time.Hour:
+		n, unit = int(d/(30*24*time.Hour)), "month"
+	default:
+		n, unit = int(d/(365*24*time.Hour)), "year"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	if future {
+		return fmt.Sprintf("in %d %s", n, unit), nil
+	}
+	return fmt.Sprintf("%d %s ago", n, unit), nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nvlled/gost/util"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Functions for strings, numbers and collections.
+// The subject comes last so that it can be piped:
+// {{.title | replace "-" " " | upper}}
+
+var stdFuncMap = template.FuncMap{
+	// strings
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"title":    titleCase,
+	"trim":     strings.TrimSpace,
+	"replace":  func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+	"split":    func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":     func(sep string, list interface{}) string { return joinList(sep, list) },
+	"truncate": truncate,
+	"slugify":  util.Slugify,
+	"contains": func(substr, s string) bool { return strings.Contains(s, substr) },
+
+	// numbers
+	"add": func(x, y interface{}) (interface{}, error) { return arith("add", x, y) },
+	"sub": func(x, y interface{}) (interface{}, error) { return arith("sub", x, y) },
+	"mul": func(x, y interface{}) (interface{}, error) { return arith("mul", x, y) },
+	"div": func(x, y interface{}) (interface{}, error) { return arith("div", x, y) },
+	"mod": func(x, y interface{}) (interface{}, error) { return arith("mod", x, y) },
+
+	// collections
+	"dict":    dict,
+	"list":    func(items ...interface{}) []interface{} { return items },
+	"append":  func(list interface{}, items ...interface{}) []interface{} { return append(toList(list), items...) },
+	"in":      in,
+	"uniq":    uniq,
+	"reverse": reverse,
+
+	// conversions, invalid values are converted to the zero value
+	"to_int":    toInt,
+	"to_float":  toFloat,
+	"to_string": toString,
+	"to_bool":   toBool,
+}
+
+func titleCase(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		defer func() { prev = r }()
+		if unicode.IsSpace(prev) || prev == '-' {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
+
+// cuts s to n characters, adding an ellipsis if s is cut
+func truncate(n int, s string) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
+
+func joinList(sep string, list interface{}) string {
+	var items []string
+	for _, item := range toList(list) {
+		items = append(items, toString(item))
+	}
+	return strings.Join(items, sep)
+}
+
+// converts a slice or array of any type into a []interface{},
+// other values become a list with a single item
+func toList(v interface{}) []interface{} {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []interface{}{v}
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list
+}
+
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict: odd number of arguments")
+	}
+	m := make(map[string]interface{})
+	for i := 0; i < len(pairs); i += 2 {
+		m[toString(pairs[i])] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// tells whether item is in the list, is a key of
+// the map, or is a substring of the string
+func in(collection, item interface{}) bool {
+	if collection == nil {
+		return false
+	}
+	rv := reflect.ValueOf(collection)
+	switch rv.Kind() {
+	case reflect.String:
+		return strings.Contains(rv.String(), toString(item))
+	case reflect.Map:
+		for _, k := range rv.MapKeys() {
+			if toString(k.Interface()) == toString(item) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, x := range toList(collection) {
+		if reflect.DeepEqual(x, item) || toString(x) == toString(item) {
+			return true
+		}
+	}
+	return false
+}
+
+func uniq(list interface{}) []interface{} {
+	var result []interface{}
+	seen := make(map[string]bool)
+	for _, item := range toList(list) {
+		k := fmt.Sprintf("%T:%v", item, item)
+		if !seen[k] {
+			seen[k] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func reverse(list interface{}) []interface{} {
+	items := toList(list)
+	result := make([]interface{}, len(items))
+	for i, item := range items {
+		result[len(items)-1-i] = item
+	}
+	return result
+}
+
+// numbers are ints if possible, otherwise float64
+func toNumber(v interface{}) (int64, float64, bool, error) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), float64(n), true, nil
+	case int64:
+		return n, float64(n), true, nil
+	case float64:
+		return int64(n), n, false, nil
+	case float32:
+		return int64(n), float64(n), false, nil
+	}
+	s := strings.TrimSpace(toString(v))
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, float64(i), true, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("not a number: %v", v)
+	}
+	return int64(f), f, false, nil
+}
+
+func arith(op string, x, y interface{}) (interface{}, error) {
+	xi, xf, xInt, err := toNumber(x)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	yi, yf, yInt, err := toNumber(y)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	if (op == "div" || op == "mod") && yf == 0 {
+		return nil, errors.New(op + ": division by zero")
+	}
+	if xInt && yInt {
+		switch op {
+		case "add":
+			return xi + yi, nil
+		case "sub":
+			return xi - yi, nil
+		case "mul":
+			return xi * yi, nil
+		case "div":
+			return xi / yi, nil
+		case "mod":
+			return xi % yi, nil
+		}
+	}
+	switch op {
+	case "add":
+		return xf + yf, nil
+	case "sub":
+		return xf - yf, nil
+	case "mul":
+		return xf * yf, nil
+	case "div":
+		return xf / yf, nil
+	}
+	return nil, errors.New(op + ": requires integers")
+}
+
+func toInt(v interface{}) int {
+	i, _, _, _ := toNumber(v)
+	return int(i)
+}
+
+func toFloat(v interface{}) float64 {
+	_, f, _, _ := toNumber(v)
+	return f
+}
+
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// true, yes, on and non-zero numbers are true
+func toBool(v interface{}) bool {
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	s := strings.ToLower(strings.TrimSpace(toString(v)))
+	switch s {
+	case "true", "yes", "on":
+		return true
+	case "", "false", "no", "off":
+		return false
+	}
+	_, f, _, err := toNumber(s)
+	return err == nil && f != 0
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStdFuncs(t *testing.T) {
+	testData := [][]string{
+		// template expected
+		{`{{"hello-world" | replace "-" " " | title}}`, "Hello World"},
+		{`{{"abcdef" | truncate 3}}`, "abc…"},
+		{`{{"a b c" | split " " | reverse | join ","}}`, "c,b,a"},
+		{`{{list "a" "b" "a" | uniq | join ""}}`, "ab"},
+		{`{{add 1 2}} {{add "1" "2.5"}} {{sub 5 "2"}} {{div 7 2}} {{mod 7 2}}`, "3 3.5 3 3 1"},
+		{`{{in (list "x" "y") "y"}} {{in "abc" "d"}} {{in (dict "k" 1) "k"}}`, "true false true"},
+		{`{{to_int "42"}} {{to_int "x"}} {{to_bool "yes"}} {{to_bool "0"}}`, "42 0 true false"},
+		{`{{(dict "a" 1).a}}`, "1"},
+		{`{{append (list 1) 2 3 | join "+"}}`, "1+2+3"},
+	}
+	testTemplates(t, testData)
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tmpl := createTemplate()
+	tmpl.Parse(`{{div 1 0}}`)
+	if err := tmpl.Execute(new(bytes.Buffer), nil); err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestDateFuncs(t *testing.T) {
+	oldBuildTime, oldTimeZone := buildTime, timeZone
+	defer func() {
+		buildTime, timeZone = oldBuildTime, oldTimeZone
+	}()
+	buildTime = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	timeZone = time.UTC
+	testData := [][]string{
+		// template expected
+		{`{{now | date_format "date"}}`, "2024-03-01"},
+		{`{{"2024-02-03" | date_format "%d %b %Y, %A"}}`, "03 Feb 2024, Saturday"},
+		{`{{"2024-02-03" | date_format "%-d/%-m/%y"}}`, "3/2/24"},
+		{`{{"2024-02-03 10:30" | date_format "Jan 2 15:04"}}`, "Feb 3 10:30"},
+		{`{{parse_date "02/01/2006" "03/02/2024" | date_format "rfc3339"}}`, "2024-02-03T00:00:00Z"},
+		{`{{"2024-03-01T12:00:00Z" | in_zone "Asia/Tokyo" | date_format "15:04 MST"}}`, "21:00 JST"},
+		{`{{0 | date_format "2006"}}`, "1970"},
+		{`{{"2024-02-28" | add_date 0 0 2 | date_format "date"}}`, "2024-03-01"},
+		{`{{time_ago "2024-02-28"}} {{time_ago "2024-03-01 15:00"}} {{time_ago "2020-01-01"}}`,
+			"2 days ago in 3 hours 4 years ago"},
+	}
+	testTemplates(t, testData)
+}
+
+// each row has a template and its expected output
+func testTemplates(t *testing.T, testData [][]string) {
+	for _, row := range testData {
+		tmpl, err := createTemplate().Parse(row[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := new(bytes.Buffer)
+		if err := tmpl.Execute(buf, nil); err != nil {
+			t.Error(row[0], err)
+			continue
+		}
+		if buf.String() != row[1] {
+			t.Error(row[0], "| Expected", row[1], "got", buf.String())
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/nvlled/gost/genv"
+	"github.com/nvlled/gost/util"
+	"os"
+	fpath "path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// An itemplate with a generate entry is a page generator.
+// Instead of being built as is, it is rendered once for
+// each item of a data source:
+//
+//   generate: data/products.json     JSON array, relative to srcDir
+//   generate: data/people.csv        CSV with a header row
+//   generate: list tags              words in the tags entry
+//   generate: index category:article itemplates that match the query
+//
+// Each page gets the env of the generator with the entries
+// item and itemIndex, and its output path is given
+// by the generate-path template:
+//
+//   generate-path: /products/{{.item.slug}}.html
+//
+// Generated pages are added to the index
+// if generate-id is given, which is also a template.
+
+type generator struct {
+	path string
+	env  genv.T
+}
+
+// page generators found by buildIndex
+var generators []generator
+
+// envs of the generated pages, by path of the generator
+var generated map[string][]genv.T
+
+// generators are run after the index is built
+// so that index queries see all the other itemplates
+func runGenerators(state *gostState) {
+	for _, g := range generators {
+		items, err := loadItems(state, g.env)
+		fail(err)
+		// generators without items are not built as pages either
+		generated[g.path] = []genv.T{}
+		for i, item := range items {
+			env := genv.New()
+			env.SetParent(g.env)
+			env.Set("item", item)
+			env.Set("itemIndex", i)
+			env.Unset("id")
+
+			path, err := execEnvTemplate(env, generatePathKey)
+			fail(err)
+			if path == "" {
+				fail(errors.New("missing " + generatePathKey + " in " + g.path))
+			}
+			setPagePath(env, fpath.Join("/", path))
+
+			id, err := execEnvTemplate(env, generateIdKey)
+			fail(err)
+			if id != "" {
+				env.Set("id", id)
+			}
+			addToIndex(state, g.path+"#"+strconv.Itoa(i), env)
+			addToSection(state, g.path, env)
+			generated[g.path] = append(generated[g.path], env)
+		}
+	}
+}
+
+// executes the template in the entry key of env
+func execEnvTemplate(env genv.T, key string) (string, error) {
+	s := env.Get(key)
+	if s == "" {
+		return "", nil
+	}
+	t, err := createTemplate().Parse(s)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, env.Entries())
+	return strings.TrimSpace(buf.String()), err
+}
+
+// the generate entry only applies to the itemplate itself,
+// like the local entries, so that the itemplates under a
+// directory whose env has a generate entry are not generators
+func isGenerator(env genv.T) bool {
+	s, _ := env.OwnEntries()[generateKey].(string)
+	return s != ""
+}
+
+func loadItems(state *gostState, env genv.T) ([]interface{}, error) {
+	spec := strings.Fields(env.Get(generateKey))
+	if len(spec) == 0 {
+		return nil, errors.New("missing data source in " + generateKey)
+	}
+	var items []interface{}
+
+	switch spec[0] {
+	case "list":
+		if len(spec) < 2 {
+			return nil, errors.New("usage: generate: list <key>")
+		}
+		for _, s := range strings.Fields(env.Get(spec[1])) {
+			items = append(items, s)
+		}
+	case "index":
+		query := parseIndexQuery(state, spec[1:])
+		for _, env := range query.run() {
+			items = append(items, env.Entries())
+		}
+	default:
+		filename := util.PrependPath(spec[0], state.srcDir)
+		switch fpath.Ext(filename) {
+		case ".json":
+			return readJSONItems(filename)
+		case ".csv":
+			return readCSVItems(filename)
+		}
+		return nil, errors.New("unknown data source: " + spec[0])
+	}
+	return items, nil
+}
+
+func readJSONItems(filename string) ([]interface{}, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var items []interface{}
+	err = json.NewDecoder(file).Decode(&items)
+	return items, err
+}
+
+// each row is a map with the header row as keys
+func readCSVItems(filename string) ([]interface{}, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	var items []interface{}
+	header := rows[0]
+	for _, row := range rows[1:] {
+		item := make(map[string]interface{})
+		for i, k := range header {
+			if i < len(row) {
+				item[k] = row[i]
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func renderGenerated(state *gostState, t *template.Template, srcPath string, envs []genv.T) error {
+	contents := genv.ReadContents(srcPath)
+	for _, env := range envs {
+		destPath := fpath.Join(state.destDir, pageFile(env.Get("path")))
+		state.out.mkdir(fpath.Dir(destPath))
+
+		s := renderItemplate(state, t, srcPath, contents, env)
+		if err := state.out.render(srcPath, destPath, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
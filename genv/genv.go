@@ -16,8 +16,19 @@ const (
 	FILENAME = "env"
 	SEP      = ":"
 	LINE_SEP = "---"
+
+	// key: !unset removes the inherited value of key
+	UNSET = "!unset"
+	// @key: value applies only to the env itself,
+	// and is not inherited by sub-envs
+	LOCAL_PREFIX = "@"
 )
 
+// marks an unset entry
+type unsetValue struct{}
+
+func (unsetValue) String() string { return UNSET }
+
 // Since I want to be able to do something
 // like {{.someValue}} using an env as a context,
 // I need to use maps[string]interface{}.
@@ -26,11 +37,14 @@ const (
 
 type T interface {
 	Set(k string, v interface{})
+	Unset(k string)
 	SetParent(T)
 	GetOk(k string) (string, bool)
 	Get(k string) string
 	GetOr(k, defValue string) string
 	Entries() map[string]interface{}
+	OwnEntries() map[string]interface{}
+	IsLocal(k string) bool
 	Parent() T
 	Normalize()
 	ClearBuffer()
@@ -38,6 +52,8 @@ type T interface {
 	Extend(T) T
 	OverrideBase(newBaseEnv T)
 	String() string
+	Source() string
+	SetSource(string)
 }
 
 type genv struct {
@@ -45,12 +61,24 @@ type genv struct {
 	buffer   map[string]interface{}
 	parent   T
 	buffered bool
+
+	// keys whose values are set with Set,
+	// these are not subject to interpolation
+	literals map[string]bool
+	// keys that are not inherited by sub-envs
+	locals map[string]bool
+
+	// where the entries were read from,
+	// usually a filename
+	source string
 }
 
 func newGenv() *genv {
 	return &genv{
 		entries:  make(map[string]interface{}),
 		buffer:   make(map[string]interface{}),
+		literals: make(map[string]bool),
+		locals:   make(map[string]bool),
 		buffered: false,
 		parent:   nil,
 	}
@@ -81,14 +109,10 @@ func (env *genv) getOk(k string) (string, bool) {
 	}
 }
 
+// the entries already include the inherited ones,
+// minus the unset and local entries of parent envs
 func (env *genv) GetOk(k string) (string, bool) {
-	if v, ok := env.getOk(k); ok {
-		return v, ok
-	}
-	if env.parent != nil {
-		return env.parent.GetOk(k)
-	}
-	return "", false
+	return env.getOk(k)
 }
 
 func (env *genv) Get(k string) string {
@@ -105,9 +129,24 @@ func (env *genv) GetOr(k string, defValue string) string {
 
 func (env *genv) Set(k string, v interface{}) {
 	env.entries[k] = v
+	env.literals[k] = true
+	env.buffered = false
+}
+
+// removes the entry k, including the inherited value
+func (env *genv) Unset(k string) {
+	env.entries[k] = unsetValue{}
 	env.buffered = false
 }
 
+func (env *genv) Source() string {
+	return env.source
+}
+
+func (env *genv) SetSource(source string) {
+	env.source = source
+}
+
 func (env *genv) Parent() T {
 	return env.parent
 }
@@ -120,28 +159,66 @@ func (env *genv) SetParent(parent T) {
 // Buffering (or should I say caching) has
 // a problem when parent envs are mutated
 // causing the child envs to have non-updated entries.
+//
+// Panics with a *CycleError when the values
+// contain cyclic references.
 func (env *genv) Entries() map[string]interface{} {
 	if env.buffered {
 		return env.buffer
 	}
-	buffer := make(map[string]interface{})
-	if env.parent != nil {
+	entries, literals := env.mergedEntries(false)
+	buffer, err := interpolate(entries, literals)
+	if err != nil {
+		panic(err)
+	}
+	env.buffer = buffer
+	env.buffered = true
+	return buffer
+}
+
+// returns the entries, including the inherited ones,
+// before interpolation. Local entries are omitted
+// if the entries are for a sub-env.
+func (env *genv) mergedEntries(forSubEnv bool) (map[string]interface{}, map[string]bool) {
+	entries := make(map[string]interface{})
+	literals := make(map[string]bool)
+	if parent, ok := env.parent.(*genv); ok {
+		entries, literals = parent.mergedEntries(true)
+	} else if env.parent != nil {
 		for k, v := range env.parent.Entries() {
-			buffer[k] = v
+			entries[k] = v
+			literals[k] = true
 		}
 	}
 	for k, v := range env.entries {
-		if v == nil {
+		if v == nil || (forSubEnv && env.locals[k]) {
 			continue
 		}
 		if s, ok := v.(string); ok && s == "" {
 			continue
 		}
-		buffer[k] = v
+		if _, ok := v.(unsetValue); ok {
+			delete(entries, k)
+			delete(literals, k)
+			continue
+		}
+		entries[k] = v
+		literals[k] = env.literals[k]
 	}
-	env.buffer = buffer
-	env.buffered = true
-	return buffer
+	return entries, literals
+}
+
+// returns the entries of env without the inherited ones
+func (env *genv) OwnEntries() map[string]interface{} {
+	entries := make(map[string]interface{})
+	for k, v := range env.entries {
+		entries[k] = v
+	}
+	return entries
+}
+
+func (env *genv) IsLocal(k string) bool {
+	return env.locals[k]
 }
 
 func (self *genv) ClearBuffer() {
@@ -227,7 +304,18 @@ func Parse(s string) T {
 		if len(sub) == 2 {
 			k := strings.TrimSpace(sub[0])
 			v := strings.TrimSpace(sub[1])
-			env.entries[k] = v
+			if strings.HasPrefix(k, LOCAL_PREFIX) {
+				k = strings.TrimPrefix(k, LOCAL_PREFIX)
+				env.locals[k] = true
+			}
+			if k == "" {
+				continue
+			}
+			if v == UNSET {
+				env.entries[k] = unsetValue{}
+			} else {
+				env.entries[k] = v
+			}
 		}
 	}
 	return env
@@ -240,11 +328,13 @@ func ReadAll(baseDir, path string) T {
 		subEnv.SetParent(env)
 		env = subEnv
 	}
-	if subEnv, err := ReadFile(fpath.Join(baseDir, path)); err == nil {
-		subEnv.SetParent(env)
-		env = subEnv
+	filename := fpath.Join(baseDir, path)
+	if util.DirExists(filename) {
+		return env
 	}
-	return env
+	subEnv := ReadEnv(filename)
+	subEnv.SetParent(env)
+	return subEnv
 }
 
 func ReadFile(filename string) (T, error) {
@@ -257,7 +347,9 @@ func ReadFile(filename string) (T, error) {
 		return newGenv(), err
 	}
 
-	return Parse(string(bytes)), nil
+	env := Parse(string(bytes))
+	env.SetSource(filename)
+	return env, nil
 }
 
 func ReadDir(dir string) T {
@@ -285,7 +377,9 @@ func ReadEnv(path string) T {
 		return newGenv()
 	}
 	lines = lines[start:end]
-	return Parse(strings.Join(lines, "\n"))
+	env := Parse(strings.Join(lines, "\n"))
+	env.SetSource(path)
+	return env
 }
 
 func ReadContents(path string) string {
@@ -0,0 +1,81 @@
+package genv
+
+import (
+	"os"
+	"testing"
+)
+
+func TestInterpolation(t *testing.T) {
+	os.Setenv("GENV_TEST_USER", "nobody")
+
+	parent := Parse(`
+sitename: sampel
+og-title: ${title} - ${sitename}
+greeting: hello ${env:GENV_TEST_USER}
+fallback: ${nothing:-default value}
+escaped: $${title} costs $$5
+`)
+	env := Parse("title: Welcome")
+	env.SetParent(parent)
+	env.Set("contents", "${title}")
+
+	testData := [][]string{
+		// key expected
+		{"og-title", "Welcome - sampel"},
+		{"greeting", "hello nobody"},
+		{"fallback", "default value"},
+		{"escaped", "${title} costs $5"},
+		{"contents", "${title}"},
+	}
+	for _, row := range testData {
+		result := env.Get(row[0])
+		if result != row[1] {
+			t.Error("key =", row[0], "| Expected", row[1], "got", result)
+		}
+	}
+}
+
+func TestInterpolationCycle(t *testing.T) {
+	env := Parse(`
+a: ${b}
+b: x ${a}
+`)
+	defer func() {
+		err := recover()
+		if _, ok := err.(*CycleError); !ok {
+			t.Error("Expected a *CycleError, got", err)
+		}
+	}()
+	env.Entries()
+}
+
+func TestUnsetAndLocal(t *testing.T) {
+	parent := Parse(`
+layout: default.html
+category: article
+@id: articles
+`)
+	env := Parse(`
+layout: !unset
+@title: local title
+`)
+	env.SetParent(parent)
+	child := Parse("x: 1")
+	child.SetParent(env)
+
+	if _, ok := env.GetOk("layout"); ok {
+		t.Error("Expected layout to be unset")
+	}
+	if _, ok := env.GetOk("id"); ok {
+		t.Error("Expected id of parent to be not inherited")
+	}
+	if env.Get("title") != "local title" {
+		t.Error("Expected local title, got", env.Get("title"))
+	}
+	if _, ok := child.GetOk("title"); ok {
+		t.Error("Expected title of parent to be not inherited")
+	}
+	if child.Get("category") != "article" {
+		t.Error("Expected category to be inherited, got", child.Get("category"))
+	}
+}
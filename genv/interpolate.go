@@ -0,0 +1,152 @@
+package genv
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Env values may contain references that are expanded
+// when the entries are read:
+//
+//   ${key}            value of key in the env (or its parents)
+//   ${env:HOME}       value of the OS environment variable HOME
+//   ${key:-default}   default is used when the value is empty
+//   $$                a literal $
+//
+// References are resolved against the merged entries, so
+// a value in a parent env can refer to a key that
+// is only defined in a child env.
+
+const (
+	OS_ENV_PREFIX = "env:"
+	DEFAULT_SEP   = ":-"
+)
+
+type CycleError struct {
+	Keys []string
+}
+
+func (e *CycleError) Error() string {
+	return "env reference cycle: " + strings.Join(e.Keys, " -> ")
+}
+
+type interpolator struct {
+	entries  map[string]interface{}
+	literals map[string]bool
+
+	resolved map[string]string
+	visiting []string
+}
+
+// expands the references in the values of entries,
+// except for the keys in literals.
+// The given entries are not modified.
+func interpolate(entries map[string]interface{}, literals map[string]bool) (map[string]interface{}, error) {
+	in := &interpolator{
+		entries:  entries,
+		literals: literals,
+		resolved: make(map[string]string),
+	}
+	result := make(map[string]interface{})
+	for k, v := range entries {
+		if _, ok := v.(string); ok && !literals[k] {
+			s, err := in.resolve(k)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = s
+		} else {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+func (in *interpolator) resolve(key string) (string, error) {
+	if s, ok := in.resolved[key]; ok {
+		return s, nil
+	}
+	v, ok := in.entries[key]
+	if !ok {
+		return "", nil
+	}
+	s, isString := v.(string)
+	if !isString {
+		return fmt.Sprintf("%v", v), nil
+	}
+	if in.literals[key] {
+		return s, nil
+	}
+
+	for i, k := range in.visiting {
+		if k == key {
+			keys := append([]string{}, in.visiting[i:]...)
+			return "", &CycleError{append(keys, key)}
+		}
+	}
+	in.visiting = append(in.visiting, key)
+	s, err := Expand(s, in.resolve)
+	in.visiting = in.visiting[:len(in.visiting)-1]
+	if err != nil {
+		return "", err
+	}
+	in.resolved[key] = s
+	return s, nil
+}
+
+// Expand replaces the references in s,
+// using lookup to get the value of env keys.
+func Expand(s string, lookup func(key string) (string, error)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+		if s[i+1] == '$' {
+			buf.WriteByte('$')
+			i++
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if s[i+1] != '{' || end < 0 {
+			buf.WriteByte(s[i])
+			continue
+		}
+		ref := s[i+2 : i+end]
+		v, err := expandRef(ref, lookup)
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(v)
+		i += end
+	}
+	return buf.String(), nil
+}
+
+func expandRef(ref string, lookup func(string) (string, error)) (string, error) {
+	name, defValue := ref, ""
+	if i := strings.Index(ref, DEFAULT_SEP); i >= 0 {
+		name, defValue = ref[:i], ref[i+len(DEFAULT_SEP):]
+	}
+	name = strings.TrimSpace(name)
+
+	var v string
+	if strings.HasPrefix(name, OS_ENV_PREFIX) {
+		v = os.Getenv(strings.TrimPrefix(name, OS_ENV_PREFIX))
+	} else {
+		var err error
+		v, err = lookup(name)
+		if err != nil {
+			return "", err
+		}
+	}
+	if v == "" {
+		return defValue, nil
+	}
+	return v, nil
+}
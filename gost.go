@@ -5,12 +5,14 @@ import (
 	//"github.com/nvlled/gost/defaults"
 	"errors"
 	"github.com/nvlled/gost/genv"
+	"github.com/nvlled/gost/highlight"
 	"github.com/nvlled/gost/util"
 	"gopkg.in/fsnotify.v1"
 	"io/ioutil"
 	"log"
 	"os"
 	fpath "path/filepath"
+	"sort"
 	"strings"
 
 	// *** note:
@@ -25,6 +27,8 @@ import (
 const (
 	// distdel: directory is safe to delete
 	MARKER_NAME = ".gost-distdel"
+	// list of the files written by the builds, used by clean
+	OUTPUTS_NAME = ".gost-outputs"
 )
 
 type Index map[string]genv.T
@@ -80,7 +84,9 @@ var actions = map[string]action{
 		handler: func(opts *gostOpts, _ []string) {
 			validateOpts(opts, fullCheck...)
 			state := optsToState(opts)
-			runBuild(state)
+			if err := runBuild(state); err != nil {
+				os.Exit(1)
+			}
 		},
 	},
 	"watch": action{
@@ -100,8 +106,12 @@ var actions = map[string]action{
 
 			printLog("watching", srcDir)
 			watcher, err := fsnotify.NewWatcher()
-			util.RecursiveWatch(watcher, srcDir)
 			fail(err)
+			util.RecursiveWatch(watcher, srcDir)
+			for _, t := range state.themes {
+				printLog("watching", t.dir)
+				util.RecursiveWatch(watcher, t.dir)
+			}
 			rebuild := util.Throttle(func() { runBuild(state) }, 900)
 			for {
 				select {
@@ -119,6 +129,8 @@ var actions = map[string]action{
                 |If dest is a directory created from build action,
                 |as indicated by the presence of .gost-distdel in it,
                 |then it is deleted.
+                |Otherwise, only the files listed in .gost-outputs
+                |by the previous builds are deleted.
                 `),
 		handler: func(opts *gostOpts, _ []string) {
 			validateOpts(opts, fullCheck...)
@@ -185,9 +197,9 @@ var actions = map[string]action{
 				return
 			}
 
-			index = make(Index)
-			pathIndex = make(Index)
+			resetIndex()
 			buildIndex(state, state.srcDir, genv.New())
+			runGenerators(state)
 			env := pathIndex[path]
 
 			if env == nil {
@@ -199,31 +211,34 @@ var actions = map[string]action{
 			}
 
 			t := createTemplate()
-			printLog("loading includes", state.includesDir)
-			globTemplates(t, includesKey, state.includesDir)
-			printLog("loading layouts", state.layoutsDir)
-			globTemplates(t, layoutsKey, state.layoutsDir)
+			globThemeTemplates(t, state, includesKey)
+			globThemeTemplates(t, state, layoutsKey)
 
 			s := genv.ReadContents(path)
-			s = applyTemplate(t, s, env)
-
-			if fpath.Ext(path) == ".html" {
-				s = applyLayout(t, s, env)
-			}
+			s = renderItemplate(state, t, path, s, env)
 			println(s)
 		},
 	},
 	"show-env": action{
-		help: util.Detab(`usage: %s --srcDir <dir> %s <filename>
+		help: util.Detab(`usage: %s --srcDir <dir> %s [-explain] <filename>
 
                 |Shows the complete env for a directory or itemplate.
+                |With -explain, each entry is shown with its source
+                |along with the values it shadows.
                 `),
 		handler: func(opts *gostOpts, args []string) {
 			validateOpts(opts, srcDirSet, srcDirExists)
 			state := optsToState(opts)
+			defer catchError()
+
+			explain := false
+			if len(args) > 1 && (args[1] == "-explain" || args[1] == "--explain") {
+				explain = true
+				args = append(args[:1], args[2:]...)
+			}
 
 			if len(args) < 2 {
-				println("missings args: " + args[0] + " <itemplate or directory>")
+				println("missings args: " + args[0] + " [-explain] <itemplate or directory>")
 				return
 			}
 			path := args[1]
@@ -231,30 +246,69 @@ var actions = map[string]action{
 			env := genv.ReadAll(state.srcDir, path)
 			//env = env.Extend(state.baseEnv)
 			env.OverrideBase(state.baseEnv)
-			println(env.String())
+			if explain {
+				println(explainEnv(state, env))
+			} else {
+				println(env.String())
+			}
+		},
+	},
+	"highlight-css": action{
+		help: util.Detab(`usage: %s %s
+
+                |Prints the stylesheet for the code blocks
+                |highlighted at build time.
+                `),
+		handler: func(_ *gostOpts, _ []string) {
+			fmt.Print(highlight.Stylesheet())
+		},
+	},
+	"index": action{
+		help: util.Detab(`usage: %s --srcDir <dir> %s [key:value...] [path...]
+
+                |Prints the resolved env of every itemplate as JSON.
+                |Arguments of the form key:value only include
+                |the itemplates whose env contains the entry.
+                |Other arguments are path prefixes relative
+                |to the srcDir, such as /articles, which
+                |also match the output paths, such as /ja.
+                `),
+		handler: func(opts *gostOpts, args []string) {
+			validateOpts(opts, srcDirSet, srcDirExists)
+			state := optsToState(opts)
+			defer catchError()
+
+			// keep the stdout clean for the JSON output
+			verbose = false
+			loadIndex(state)
+
+			query := parseIndexQuery(state, args[1:])
+			data, err := indexToJSON(query.run())
+			fail(err)
+			fmt.Println(string(data))
 		},
 	},
 }
 
-func runBuild(state *gostState) {
-	defer catchError()
+// builds the site between the hooks, errors are printed and returned
+func runBuild(state *gostState) (err error) {
+	defer catchErrorAs(&err)
 
-	index = make(Index)
-	pathIndex = make(Index)
+	fail(runHook(state, preBuildHook))
 
 	printLog("building index...")
-	buildIndex(state, state.srcDir, state.baseEnv)
+	loadIndex(state)
 
 	t := createTemplate()
-	printLog("loading includes", state.includesDir)
-	globTemplates(t, includesKey, state.includesDir)
-
-	printLog("loading layouts", state.layoutsDir)
-	globTemplates(t, layoutsKey, state.layoutsDir)
+	globThemeTemplates(t, state, includesKey)
+	globThemeTemplates(t, state, layoutsKey)
 
 	printLog("building output...", state.layoutsDir)
 	buildOutput(state, t)
+
+	fail(runHook(state, postBuildHook))
 	println("** done.")
+	return nil
 }
 
 func newProjectFile(state *gostState, path, title string) {
@@ -283,7 +337,6 @@ func newProjectFile(state *gostState, path, title string) {
 	}
 
 	protoName := env.Get(protoKey)
-	protoDir := state.protosDir
 
 	if protoName == "" {
 		println("no prototype for file", fullpath)
@@ -293,19 +346,22 @@ func newProjectFile(state *gostState, path, title string) {
 
 	t := createTemplate()
 	t.Delims(protoOpenDelim, protoCloseDelim)
-	globTemplates(t, protoKey, protoDir)
+	globThemeTemplates(t, state, protoKey)
 
 	t = t.Lookup(protoName)
 	if t == nil {
 		println("prototype not found:", protoName)
 		return
 	}
-	file, err := os.Create(fullpath)
+	file, err := state.out.create(fullpath)
 	fail(err)
-	printLog("using", "`"+protoName+"`", "prototype from", protoDir)
+	defer file.Close()
+	printLog("using", "`"+protoName+"`", "prototype")
 	err = t.ExecuteTemplate(file, protoName, env.Entries())
-	printLog("file created ->", fullpath)
 	fail(err)
+	if !state.out.dryRun {
+		printLog("file created ->", fullpath)
+	}
 }
 
 func cleanBuildDir(state *gostState) {
@@ -314,16 +370,23 @@ func cleanBuildDir(state *gostState) {
 
 	if isValidBuildDir(destDir) {
 		printLog("cleaning", destDir)
-		os.RemoveAll(destDir)
+		state.out.removeAll(destDir)
+		return
+	}
+
+	outputs, err := readOutputList(destDir)
+	if err != nil {
+		panic(err)
+	}
+	if outputs != nil {
+		removeOutputs(state, outputs)
 		return
 	}
 
+	// without an output list, from a build of an older version,
+	// only files with the names of the sources are removed
 	dirs, err := util.ReadDir(destDir, func(path string) bool {
-		path = fpath.Join(srcDir, strings.TrimPrefix(path, destDir))
-		if _, err := os.Lstat(path); err == nil {
-			return false
-		}
-		return true
+		return !state.sourceExists(strings.TrimPrefix(path, destDir))
 	})
 	if err != nil {
 		panic(err)
@@ -334,9 +397,158 @@ func cleanBuildDir(state *gostState) {
 			println("** error: cannot clean source directory", srcDir, "...skipping")
 			continue
 		}
-		printLog("removing", dir)
-		os.RemoveAll(dir)
+		state.out.removeAll(dir)
+	}
+}
+
+// returns the files in the output list of destDir, relative
+// to destDir, or nil if there is no output list
+func readOutputList(destDir string) ([]string, error) {
+	data, err := ioutil.ReadFile(fpath.Join(destDir, OUTPUTS_NAME))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var outputs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			outputs = append(outputs, line)
+		}
+	}
+	return append(outputs, OUTPUTS_NAME), nil
+}
+
+// returns the path relative to destDir of path, which is
+// relative to destDir, or false if it is not in destDir
+func destRelativePath(destDir, path string) (string, bool) {
+	if fpath.IsAbs(path) {
+		return "", false
+	}
+	rel, err := fpath.Rel(destDir, fpath.Join(destDir, path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(fpath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// writes the files written by the build in the output list,
+// along with the files of the previous builds that still exist
+func writeOutputList(state *gostState) error {
+	destDir := state.destDir
+	out := state.out
+	outputs, err := readOutputList(destDir)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, path := range outputs {
+		path, ok := destRelativePath(destDir, path)
+		if ok && !seen[path] && path != OUTPUTS_NAME && out.exists(fpath.Join(destDir, path)) {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for path := range out.written {
+		rel, err := fpath.Rel(destDir, path)
+		if err != nil {
+			continue
+		}
+		rel, ok := destRelativePath(destDir, rel)
+		if !ok || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	data := strings.Join(paths, "\n") + "\n"
+	return out.writeFile(fpath.Join(destDir, OUTPUTS_NAME), []byte(data))
+}
+
+// removes the files of the output list, and
+// the directories that they leave empty.
+// Files that are not in destDir are skipped.
+func removeOutputs(state *gostState, outputs []string) {
+	destDir := fpath.Clean(state.destDir)
+	for _, path := range outputs {
+		rel, ok := destRelativePath(destDir, path)
+		if !ok {
+			println("** skipping", path, "which is not in", destDir)
+			continue
+		}
+		path = fpath.Join(destDir, rel)
+		if !state.out.exists(path) {
+			continue
+		}
+		state.out.removeAll(path)
+		for dir := fpath.Dir(path); dir != destDir && strings.HasPrefix(dir, destDir); dir = fpath.Dir(dir) {
+			if !state.out.removeEmptyDir(dir) {
+				break
+			}
+		}
+	}
+}
+
+// lists each entry of env with its source, followed by
+// the values from parent envs that it shadows
+func explainEnv(state *gostState, env genv.T) string {
+	type origin struct {
+		value  interface{}
+		source string
+	}
+	origins := make(map[string][]origin)
+	for e := env; e != nil; e = e.Parent() {
+		for k, v := range e.OwnEntries() {
+			if s, ok := v.(string); v == nil || (ok && s == "") {
+				continue
+			}
+			source := envSource(state, e)
+			if e.IsLocal(k) {
+				// local entries of parent envs are not inherited
+				if e != env {
+					continue
+				}
+				source += ", local"
+			}
+			origins[k] = append(origins[k], origin{v, source})
+		}
+	}
+
+	var keys []string
+	for k := range origins {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	output := ""
+	for _, k := range keys {
+		for i, o := range origins[k] {
+			if i == 0 {
+				output += fmt.Sprintf("%s%s %v\t(%s)\n", k, genv.SEP, o.value, o.source)
+			} else {
+				output += fmt.Sprintf("    shadows %v\t(%s)\n", o.value, o.source)
+			}
+		}
+	}
+	return output
+}
+
+// describes where the entries of env came from
+func envSource(state *gostState, env genv.T) string {
+	source := env.Source()
+	switch {
+	case source == "":
+		return "unknown"
+	case source == cliEnvSource:
+		return source
+	case fpath.Base(source) != genv.FILENAME:
+		return "front matter of " + source
+	case fpath.Clean(fpath.Dir(source)) == fpath.Clean(state.srcDir):
+		return "base-env " + source
 	}
+	return "env file " + source
 }
 
 func buildIndex(state *gostState, path string, parentEnv genv.T) {
@@ -351,11 +563,12 @@ func buildIndex(state *gostState, path string, parentEnv genv.T) {
 		if fpath.Clean(path) == fpath.Clean(srcDir) {
 			// skip re-reading base-env
 			println("*** skipping baseEnv")
-			env = parentEnv
+			env = rootEnv(parentEnv)
 		} else {
 			env = genv.ReadDir(path)
 			env.SetParent(parentEnv)
 		}
+		addSection(state, path, env)
 
 		dirs, err := util.ReadDir(path, func(f string) bool {
 			return state.isFileExcluded(f)
@@ -371,47 +584,121 @@ func buildIndex(state *gostState, path string, parentEnv genv.T) {
 	} else if isItemplate(path) {
 		env := genv.ReadEnv(path)
 		env.SetParent(parentEnv)
-		env.Set("path", fpath.Join("/", strings.TrimPrefix(path, srcDir)))
+		relPath := strings.TrimPrefix(path, srcDir)
+		outPath := fpath.Join("/", state.outputPath(relPath))
+		if state.isFileVerbatim(relPath) {
+			env.Set("path", outPath)
+		} else {
+			setPagePath(env, outPath)
+		}
+		state.setLangEntries(env, relPath)
 
-		pathIndex[path] = env
-		if id, ok := env.GetOk("id"); ok {
-			if otherEnv, dokie := index[id]; dokie {
-				otherPath := otherEnv.Get("path")
-				log.Println("Duplicate id for paths", path, otherPath)
-			}
-			printLog("adding", path, "to index, id =", id)
+		if isGenerator(env) {
+			printLog("adding", path, "to page generators")
+			generators = append(generators, generator{path, env})
+			return
+		}
+		addToIndex(state, path, env)
+		addToSection(state, path, env)
+	}
+}
+
+// returns the env of the srcDir. The base-env has the -env
+// entries on top of the env file of the srcDir, which hides the
+// local entries of the file, so they are added back for the srcDir
+// like the local entries of the env files of other directories.
+func rootEnv(baseEnv genv.T) genv.T {
+	fileEnv := baseEnv.Parent()
+	if fileEnv == nil {
+		return baseEnv
+	}
+	cliEntries := baseEnv.OwnEntries()
+	var lines []string
+	for k, v := range fileEnv.OwnEntries() {
+		if _, ok := cliEntries[k]; fileEnv.IsLocal(k) && !ok {
+			lines = append(lines, fmt.Sprintf("@%s%s %v", k, genv.SEP, v))
+		}
+	}
+	if len(lines) == 0 {
+		return baseEnv
+	}
+	env := genv.Parse(strings.Join(lines, "\n"))
+	env.SetSource(fileEnv.Source())
+	env.SetParent(baseEnv)
+	return env
+}
+
+func addToIndex(state *gostState, path string, env genv.T) {
+	file := pageFile(env.Get("path"))
+	if other, ok := pageFiles[file]; ok {
+		fail(fmt.Errorf("%s and %s are both built as %s", other, path, file))
+	}
+	pageFiles[file] = path
+	pathIndex[path] = env
+	srcPaths[env.Get("path")] = path
+	if id, ok := env.GetOk("id"); ok {
+		lang := env.Get(langKey)
+		if langIndex[lang] == nil {
+			langIndex[lang] = make(Index)
+		}
+		if otherEnv, dokie := langIndex[lang][id]; dokie {
+			otherPath := otherEnv.Get("path")
+			log.Println("Duplicate id for paths", path, otherPath)
+		}
+		printLog("adding", path, "to index, id =", id)
+		langIndex[lang][id] = env
+		// translations share the id, prefer the default language
+		if _, dokie := index[id]; !dokie || lang == state.defaultLang() {
 			index[id] = env
-		} else {
-			printLog("omitting", path, "from index (no id)")
 		}
+	} else {
+		printLog("omitting", path, "from index (no id)")
 	}
 }
 
 func buildOutput(state *gostState, t *template.Template) {
 	srcDir := state.srcDir
 	destDir := state.destDir
+	out := state.out
+	aliases, err := pageAliases()
+	fail(err)
+
 	if isValidBuildDir(destDir) {
 		printLog("cleaning", destDir)
-		os.RemoveAll(destDir)
-		util.Mkdir(destDir)
+		out.removeAll(destDir)
+		out.mkdir(destDir)
 
-		_, err := os.Create(fpath.Join(destDir, MARKER_NAME))
+		err = out.writeFile(fpath.Join(destDir, MARKER_NAME), nil)
 		fail(err)
 	}
 
+	// files of the site, relative to srcDir,
+	// that override the files of the themes
+	written := make(map[string]bool)
+	copies := newJobQueue(state.jobs)
+
 	fn := func(srcPath string, info os.FileInfo, _ error) (err error) {
 		if state.isFileExcluded(srcPath) || info.IsDir() {
 			return
 		}
 
 		s := strings.TrimPrefix(srcPath, srcDir)
-		destPath := fpath.Join(destDir, s)
+		destPath := fpath.Join(destDir, state.outputPath(s))
+		env := pathIndex[srcPath]
+		if env != nil {
+			// the path of the page may differ with pretty-urls
+			destPath = fpath.Join(destDir, pageFile(env.Get("path")))
+		}
 
 		if state.isFileExcluded(s) {
 			printLog("*** skipping excluded file: " + s)
 			return
 		}
-		util.Mkdir(fpath.Dir(destPath))
+		if envs, ok := generated[srcPath]; ok {
+			written[s] = true
+			return renderGenerated(state, t, srcPath, envs)
+		}
+		out.mkdir(fpath.Dir(destPath))
 
 		if strings.HasPrefix(destPath, srcDir) {
 			println("** warning, writing to source directory")
@@ -419,24 +706,25 @@ func buildOutput(state *gostState, t *template.Template) {
 			return
 		}
 
-		env := pathIndex[srcPath]
+		written[s] = true
 		if isItemplate(srcPath) && !state.isFileVerbatim(s) {
 			s := genv.ReadContents(srcPath)
-			s = applyTemplate(t, s, env)
-
-			if fpath.Ext(srcPath) == ".html" {
-				s = applyLayout(t, s, env)
-			}
-
-			printLog("rendering", srcPath, "->", destPath)
-			err = ioutil.WriteFile(destPath, []byte(s), 0644)
+			s = renderItemplate(state, t, srcPath, s, env)
+			err = out.render(srcPath, destPath, s)
+		} else if out.dryRun {
+			// only reported, in the order of the walk
+			err = out.copyFile(srcPath, destPath)
 		} else {
-			printLog("copying", srcPath, "->", destPath)
-			err = util.CopyFile(destPath, srcPath)
+			copies.run(func() error { return out.copyFile(srcPath, destPath) })
 		}
 		return
 	}
 	fpath.Walk(srcDir, fn)
+	fail(copies.wait())
+	copyThemeFiles(state, written)
+	fail(writeSearchIndex(state))
+	fail(writeAliases(state, aliases))
+	fail(writeOutputList(state))
 }
 
 func newSampleProject(dirname string) error {
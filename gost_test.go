@@ -0,0 +1,235 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	"io/ioutil"
+	"os"
+	fpath "path/filepath"
+	"testing"
+)
+
+// creates a project with the given source files in a temporary
+// dir, and returns the options of the project with the dir
+func testProject(t *testing.T, files map[string]string) (*gostOpts, string) {
+	dir, err := ioutil.TempDir("", "gost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcDir := fpath.Join(dir, "src")
+	destDir := fpath.Join(dir, "build")
+	for name, contents := range files {
+		filename := fpath.Join(srcDir, name)
+		os.MkdirAll(fpath.Dir(filename), 0755)
+		if err := ioutil.WriteFile(filename, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return defaultOpts.merge(&gostOpts{srcDir: &srcDir, destDir: &destDir}), dir
+}
+
+// builds the site with the given source files in
+// an existing destDir, then cleans it
+func testBuildClean(t *testing.T, files map[string]string, outputs []string) {
+	opts, dir := testProject(t, files)
+	defer os.RemoveAll(dir)
+	destDir := *opts.destDir
+	// files in destDir that were not built are kept
+	os.MkdirAll(destDir, 0755)
+	keep := fpath.Join(destDir, "keep.txt")
+	ioutil.WriteFile(keep, nil, 0644)
+
+	state := optsToState(opts)
+	if err := runBuild(state); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range outputs {
+		if _, err := os.Stat(fpath.Join(destDir, name)); err != nil {
+			t.Error("Expected output", name, "got", err)
+		}
+	}
+
+	cleanBuildDir(optsToState(opts))
+	names, _ := ioutil.ReadDir(destDir)
+	if len(names) != 1 || names[0].Name() != "keep.txt" {
+		var found []string
+		for _, info := range names {
+			found = append(found, info.Name())
+		}
+		t.Error("Expected only keep.txt after clean, got", found)
+	}
+}
+
+func TestCleanPrettyURLs(t *testing.T) {
+	testBuildClean(t, map[string]string{
+		"env":                 "pretty-urls: true",
+		"about.html":          "about",
+		"articles/hello.html": "hello",
+		"articles/index.html": "articles",
+	}, []string{
+		"about/index.html",
+		"articles/hello/index.html",
+		"articles/index.html",
+	})
+}
+
+func TestCleanAliases(t *testing.T) {
+	testBuildClean(t, map[string]string{
+		"env":        "redirects-file: _redirects",
+		"about.html": "--------\naliases: /old/about.html /about-us\n--------\nabout",
+	}, []string{
+		"about.html",
+		"old/about.html",
+		"about-us/index.html",
+		"_redirects",
+	})
+}
+
+func TestCleanGenerated(t *testing.T) {
+	testBuildClean(t, map[string]string{
+		"products/env":        "names: a b\ngenerate-path: /products/{{.item}}.html",
+		"products/list.html":  "--------\ngenerate: list names\n--------\n{{.item}}",
+		"products/index.html": "products",
+		// not inherited, so notes/index.html isn't a generator
+		"notes/env":        "generate: list names",
+		"notes/index.html": "notes",
+	}, []string{
+		"notes/index.html",
+		"products/a.html",
+		"products/b.html",
+		"products/index.html",
+	})
+}
+
+func TestEmptyGenerate(t *testing.T) {
+	for _, s := range []string{"generate: ${none}", "generate: ${none}\nnone: \" \""} {
+		if _, err := loadItems(newState("", ""), genv.Parse(s)); err == nil {
+			t.Error(s, "| Expected an error")
+		}
+	}
+}
+
+func TestCleanSearchIndex(t *testing.T) {
+	testBuildClean(t, map[string]string{
+		"env":        "search-index: search.json\nsearch-terms: terms/search.json",
+		"about.html": "about",
+	}, []string{
+		"search.json",
+		"terms/search.json",
+	})
+}
+
+func TestCleanLanguages(t *testing.T) {
+	testBuildClean(t, map[string]string{
+		"env":           "languages: en fr ja",
+		"about.html":    "about",
+		"about.fr.html": "à propos",
+		"ja/about.html": "about",
+	}, []string{
+		"about.html",
+		"fr/about.html",
+		"ja/about.html",
+	})
+}
+
+func TestCleanSpaces(t *testing.T) {
+	testBuildClean(t, map[string]string{
+		"docs/my notes.html": "notes",
+		"docs/my.html":       "my",
+	}, []string{
+		"docs/my notes.html",
+		"docs/my.html",
+	})
+}
+
+func TestCleanOutsideDestDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	destDir := fpath.Join(dir, "build")
+	victim := fpath.Join(dir, "victim")
+	os.MkdirAll(destDir, 0755)
+	ioutil.WriteFile(victim, nil, 0644)
+	list := "../victim\n" + victim + "\nbuild/../../victim\n"
+	ioutil.WriteFile(fpath.Join(destDir, OUTPUTS_NAME), []byte(list), 0644)
+
+	cleanBuildDir(newState(fpath.Join(dir, "src")+"/", destDir+"/").setOutput(newOutput(false)))
+	if _, err := os.Stat(victim); err != nil {
+		t.Error("Expected", victim, "to be kept, got", err)
+	}
+}
+
+func TestGenerateNoItems(t *testing.T) {
+	testBuildClean(t, map[string]string{
+		"env":        "generate-path: /{{.item}}.html",
+		"list.html":  "--------\ngenerate: list names\n--------\n{{.item}}",
+		"empty.json": "[]",
+		"json.html":  "--------\ngenerate: empty.json\n--------\n{{.item}}",
+		"header.csv": "name,price\n",
+		"csv.html":   "--------\ngenerate: header.csv\n--------\n{{.item}}",
+		"query.html": "--------\ngenerate: index category:none\n--------\n{{.item}}",
+		"about.html": "about",
+	}, []string{
+		"about.html",
+	})
+}
+
+func TestDuplicatePageFiles(t *testing.T) {
+	testData := []map[string]string{
+		{"env": "pretty-urls: true", "about.html": "a", "about/index.html": "b"},
+		{"env": "languages: en ja", "about.ja.html": "a", "ja/about.html": "b"},
+	}
+	for _, files := range testData {
+		opts, dir := testProject(t, files)
+		defer os.RemoveAll(dir)
+		if err := runBuild(optsToState(opts)); err == nil {
+			t.Error(files, "| Expected an error")
+		}
+	}
+}
+
+func TestAliasCollidesWithFile(t *testing.T) {
+	opts, dir := testProject(t, map[string]string{
+		"logo.png":   "png",
+		"about.html": "--------\naliases: /logo.png\n--------\nabout",
+	})
+	defer os.RemoveAll(dir)
+	if err := runBuild(optsToState(opts)); err == nil {
+		t.Error("Expected an error for an alias that collides with a file")
+	}
+	data, _ := ioutil.ReadFile(fpath.Join(*opts.destDir, "logo.png"))
+	if string(data) != "png" {
+		t.Error("Expected logo.png to be kept, got", string(data))
+	}
+}
+
+func TestRootLocalEntries(t *testing.T) {
+	opts, dir := testProject(t, map[string]string{
+		"env":         "@title: Home\nsitename: site",
+		"index.html":  "home",
+		"docs/env":    "@title: Docs",
+		"docs/a.html": "a",
+	})
+	defer os.RemoveAll(dir)
+	loadIndex(optsToState(opts))
+	defer resetIndex()
+
+	testData := [][]string{
+		// section  expected title of the section  sitename
+		{"/", "Home", "site"},
+		{"/docs", "Docs", "site"},
+	}
+	for _, row := range testData {
+		env := sections[row[0]].env
+		if env.Get("title") != row[1] || env.Get("sitename") != row[2] {
+			t.Error("section =", row[0], "| Expected", row[1:], "got", env.Get("title"), env.Get("sitename"))
+		}
+	}
+	// local entries are not inherited by the files
+	for srcPath, env := range pathIndex {
+		if env.Get("title") != "" {
+			t.Error(srcPath, "| Expected no title, got", env.Get("title"))
+		}
+	}
+}
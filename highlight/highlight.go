@@ -0,0 +1,262 @@
+package highlight
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Code is highlighted by wrapping tokens in spans
+// with the following classes. The colors are
+// defined by the stylesheet from Stylesheet().
+const (
+	CLASS_PREFIX = "hl-"
+
+	Keyword   = "kw"
+	String    = "str"
+	Comment   = "com"
+	Number    = "num"
+	Builtin   = "bi"
+	Key       = "key"
+	Tag       = "tag"
+	Attribute = "attr"
+	Variable  = "var"
+)
+
+// A rule matches a token at the start of the text.
+// If group is not zero, only the submatch is highlighted
+// and the rest of the match is left as is.
+// If inner is not nil, the match is highlighted using
+// the inner rules instead of class.
+type rule struct {
+	re    *regexp.Regexp
+	class string
+	group int
+	inner []rule
+}
+
+func r(class, pattern string) rule {
+	return rule{re: regexp.MustCompile(`^(?:` + pattern + `)`), class: class}
+}
+
+func rg(class, pattern string, group int) rule {
+	return rule{re: regexp.MustCompile(`^(?:` + pattern + `)`), class: class, group: group}
+}
+
+func rinner(pattern string, inner []rule) rule {
+	return rule{re: regexp.MustCompile(`^(?:` + pattern + `)`), inner: inner}
+}
+
+func words(ws string) string {
+	return `\b(?:` + strings.Join(strings.Fields(ws), "|") + `)\b`
+}
+
+var (
+	cComment   = `//[^\n]*|/\*[\s\S]*?\*/`
+	dqString   = `"(?:\\.|[^"\\\n])*"`
+	sqString   = `'(?:\\.|[^'\\\n])*'`
+	number     = `\b(?:0[xX][0-9a-fA-F]+|\d[\d_]*(?:\.\d+)?(?:[eE][+-]?\d+)?)\b`
+	identifier = `[A-Za-z_$][\w$]*`
+)
+
+var langs = map[string][]rule{
+	"go": {
+		r(Comment, cComment),
+		r(String, dqString+"|`[^`]*`|"+sqString),
+		r(Number, number),
+		r(Keyword, words(`break case chan const continue default defer else
+			fallthrough for func go goto if import interface map package
+			range return select struct switch type var`)),
+		r(Builtin, words(`bool byte complex64 complex128 error float32 float64
+			int int8 int16 int32 int64 rune string uint uint8 uint16 uint32
+			uint64 uintptr any true false nil iota append cap close copy
+			delete len make new panic print println recover`)),
+		r("", identifier),
+	},
+	"js": {
+		r(Comment, cComment),
+		r(String, dqString+"|"+sqString+"|`(?:\\\\.|[^`\\\\])*`"),
+		r(Number, number),
+		r(Keyword, words(`async await break case catch class const continue
+			debugger default delete do else export extends finally for from
+			function if import in instanceof let new of return static super
+			switch this throw try typeof var void while with yield`)),
+		r(Builtin, words(`true false null undefined NaN Infinity console
+			document window Object Array String Number Boolean Promise JSON Math`)),
+		r("", identifier),
+	},
+	"json": {
+		rg(Key, `(`+dqString+`)\s*:`, 1),
+		r(String, dqString),
+		r(Number, `-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?`),
+		r(Keyword, words(`true false null`)),
+	},
+	"sh": {
+		r(Comment, `#[^\n]*`),
+		r(String, `"(?:\\.|[^"\\])*"|'[^']*'`),
+		r(Variable, `\$\{[^}\n]*\}|\$(?:\w+|[@#?*!$-])`),
+		r(Keyword, words(`if then else elif fi for while until do done case
+			esac function in select return break continue`)),
+		r(Builtin, words(`echo cd export local read set unset source exit
+			test printf shift trap eval exec alias`)),
+		r(Number, `\b\d+\b`),
+		r("", `[\w-]+`),
+	},
+	"html": {
+		r(Comment, `<!--[\s\S]*?-->|<![^>]*>`),
+		rinner(`</?[A-Za-z][^>]*>`, []rule{
+			rg(Tag, `(</?[\w-]+)`, 1),
+			rg(Attribute, `([\w:.-]+)=`, 1),
+			r(String, `"[^"]*"|'[^']*'`),
+			r(Attribute, `[\w:.-]+`),
+		}),
+		r(Variable, `&\w+;|&#\w+;`),
+	},
+	"css": {
+		r(Comment, `/\*[\s\S]*?\*/`),
+		r(Keyword, `@[\w-]+`),
+		rinner(`\{[^{}]*\}`, []rule{
+			r(Comment, `/\*[\s\S]*?\*/`),
+			rg(Key, `([\w-]+)\s*:`, 1),
+			r(String, dqString+"|"+sqString),
+			r(Number, `#[0-9a-fA-F]{3,8}\b|-?(?:\d*\.)?\d+(?:px|em|rem|%|vh|vw|s|ms|deg|fr)?`),
+			r(Keyword, `!important`),
+			r("", `[\w-]+`),
+		}),
+		r(String, dqString+"|"+sqString),
+		r(Tag, `[\w-]+`),
+	},
+	"yaml": {
+		r(Comment, `#[^\n]*|---|\.\.\.`),
+		rg(Key, `([\w.-][\w .-]*?)\s*:(?:\s|$)`, 1),
+		r(String, dqString+"|"+sqString),
+		r(Variable, `[&*][\w-]+`),
+		r(Keyword, words(`true false yes no on off null`)+`|~`),
+		r(Number, `-?\b\d+(?:\.\d+)?\b`),
+		r("", `[\w-]+`),
+	},
+}
+
+var aliases = map[string]string{
+	"golang":     "go",
+	"javascript": "js",
+	"shell":      "sh",
+	"bash":       "sh",
+	"console":    "sh",
+	"xml":        "html",
+	"yml":        "yaml",
+}
+
+// Languages returns the names of the supported languages
+func Languages() []string {
+	var names []string
+	for name := range langs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookup(lang string) ([]rule, bool) {
+	lang = strings.ToLower(lang)
+	if name, ok := aliases[lang]; ok {
+		lang = name
+	}
+	rules, ok := langs[lang]
+	return rules, ok
+}
+
+// Highlight returns code as html with its tokens wrapped in spans.
+// For unsupported languages, the code is only escaped.
+func Highlight(code, lang string) string {
+	rules, ok := lookup(lang)
+	if !ok {
+		return html.EscapeString(code)
+	}
+	return highlight(code, rules)
+}
+
+func highlight(code string, rules []rule) string {
+	var buf strings.Builder
+	for len(code) > 0 {
+		n := 0
+		for _, rl := range rules {
+			m := rl.re.FindStringSubmatchIndex(code)
+			if m == nil || m[1] == 0 {
+				continue
+			}
+			n = m[1]
+			switch {
+			case rl.inner != nil:
+				buf.WriteString(highlight(code[:n], rl.inner))
+			case rl.group > 0:
+				start, end := m[2*rl.group], m[2*rl.group+1]
+				buf.WriteString(html.EscapeString(code[:start]))
+				writeSpan(&buf, rl.class, code[start:end])
+				buf.WriteString(html.EscapeString(code[end:n]))
+			default:
+				writeSpan(&buf, rl.class, code[:n])
+			}
+			break
+		}
+		if n == 0 {
+			// no token, copy a single character
+			n = 1
+			for n < len(code) && code[n]&0xC0 == 0x80 {
+				n++
+			}
+			buf.WriteString(html.EscapeString(code[:n]))
+		}
+		code = code[n:]
+	}
+	return buf.String()
+}
+
+func writeSpan(buf *strings.Builder, class, s string) {
+	if class == "" {
+		buf.WriteString(html.EscapeString(s))
+		return
+	}
+	buf.WriteString(`<span class="` + CLASS_PREFIX + class + `">`)
+	buf.WriteString(html.EscapeString(s))
+	buf.WriteString(`</span>`)
+}
+
+var codeBlockRe = regexp.MustCompile(
+	`(?s)(<pre[^>]*>\s*<code[^>]*class="(?:[^"]*\s)?language-([\w+-]+)[^"]*"[^>]*>)(.*?)(</code>)`)
+
+// HighlightHTML highlights the contents of the
+// <pre><code class="language-xxx"> blocks in s.
+// Blocks that already contain tags are left as is.
+func HighlightHTML(s string) string {
+	return codeBlockRe.ReplaceAllStringFunc(s, func(block string) string {
+		m := codeBlockRe.FindStringSubmatch(block)
+		openTag, lang, code, closeTag := m[1], m[2], m[3], m[4]
+		if _, ok := lookup(lang); !ok || strings.Contains(code, "<") {
+			return block
+		}
+		return openTag + Highlight(html.UnescapeString(code), lang) + closeTag
+	})
+}
+
+var colors = []struct{ class, style string }{
+	{Keyword, "color: #8959a8; font-weight: bold;"},
+	{String, "color: #718c00;"},
+	{Comment, "color: #8e908c; font-style: italic;"},
+	{Number, "color: #f5871f;"},
+	{Builtin, "color: #3e999f;"},
+	{Key, "color: #4271ae;"},
+	{Tag, "color: #c82829;"},
+	{Attribute, "color: #eab700;"},
+	{Variable, "color: #c82829;"},
+}
+
+// Stylesheet returns the css for the highlighted code
+func Stylesheet() string {
+	css := ""
+	for _, c := range colors {
+		css += "." + CLASS_PREFIX + c.class + " { " + c.style + " }\n"
+	}
+	return css
+}
@@ -0,0 +1,37 @@
+package highlight
+
+import (
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	testData := [][]string{
+		// lang code expected
+		{"go", `return "x" // done`,
+			`<span class="hl-kw">return</span> <span class="hl-str">&#34;x&#34;</span> <span class="hl-com">// done</span>`},
+		{"go", `returned := 1`,
+			`returned := <span class="hl-num">1</span>`},
+		{"json", `{"a": true}`,
+			`{<span class="hl-key">&#34;a&#34;</span>: <span class="hl-kw">true</span>}`},
+		{"html", `<a href="/">x's</a>`,
+			`<span class="hl-tag">&lt;a</span> <span class="hl-attr">href</span>=<span class="hl-str">&#34;/&#34;</span>&gt;x&#39;s<span class="hl-tag">&lt;/a</span>&gt;`},
+		{"sh", `echo $HOME`,
+			`<span class="hl-bi">echo</span> <span class="hl-var">$HOME</span>`},
+		{"unknown", `<b>`, `&lt;b&gt;`},
+	}
+	for _, row := range testData {
+		result := Highlight(row[1], row[0])
+		if result != row[2] {
+			t.Error("lang =", row[0], "code =", row[1], "| Expected", row[2], "got", result)
+		}
+	}
+}
+
+func TestHighlightHTML(t *testing.T) {
+	input := `<pre><code class="language-go">x := &quot;a&quot;</code></pre><pre><code>nil</code></pre>`
+	expected := `<pre><code class="language-go">x := <span class="hl-str">&#34;a&#34;</span></code></pre><pre><code>nil</code></pre>`
+	result := HighlightHTML(input)
+	if result != expected {
+		t.Error("Expected", expected, "got", result)
+	}
+}
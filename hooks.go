@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Hooks are shell commands run before and after each build,
+// given by the -pre-build and -post-build options or by
+// the pre-build and post-build entries in the base-env.
+// Several commands may be separated by ; and the hook
+// fails as soon as one of them fails.
+
+const (
+	preBuildHook  = "pre-build"
+	postBuildHook = "post-build"
+
+	srcDirVar  = "GOST_SRC_DIR"
+	destDirVar = "GOST_DEST_DIR"
+)
+
+func (state *gostState) setHook(name, command string) *gostState {
+	if state.hooks == nil {
+		state.hooks = make(map[string]string)
+	}
+	state.hooks[name] = command
+	return state
+}
+
+func runHook(state *gostState, name string) error {
+	command := state.hooks[name]
+	if command == "" {
+		return nil
+	}
+	if state.out.dryRun {
+		state.out.report("run", name+":", command)
+		return nil
+	}
+	printLog("running", name+":", command)
+	cmd := exec.Command("sh", "-e", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		srcDirVar+"="+state.srcDir,
+		destDirVar+"="+state.destDir,
+	)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %v", name, err)
+	}
+	return nil
+}
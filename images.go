@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nvlled/gost/imgproc"
+	"os"
+	fpath "path/filepath"
+	"strings"
+)
+
+// derived images are kept in the image cache dir between builds,
+// and are copied to the destDir beside their source image
+
+const defaultImageCacheDir = "../.gost-cache/images"
+
+type imageInfo struct {
+	URL    string
+	Path   string
+	Width  int
+	Height int
+}
+
+// path is relative to the srcDir, as in /photos/cat.jpg
+func processImage(state *gostState, path, specStr string) (imageInfo, error) {
+	var img imageInfo
+	spec, err := imgproc.ParseSpec(specStr)
+	if err != nil {
+		return img, err
+	}
+	srcFile := fpath.Join(state.srcDir, path)
+	w, h, err := imgproc.DecodeSize(srcFile)
+	if err != nil {
+		return img, fmt.Errorf("image %s: %v", path, err)
+	}
+	img.Width, img.Height = spec.Size(w, h)
+
+	ext := fpath.Ext(path)
+	name := strings.TrimSuffix(fpath.Base(path), ext) + "_" + spec.Suffix(ext) + spec.Ext(ext)
+	img.Path = fpath.Join("/", fpath.Dir(path), name)
+
+	cacheFile := fpath.Join(state.imageCacheDir, img.Path)
+	if !state.out.dryRun && isStale(cacheFile, srcFile) {
+		printLog("processing image", srcFile, "->", cacheFile)
+		if err := imgproc.Process(srcFile, cacheFile, spec); err != nil {
+			return img, err
+		}
+	}
+
+	destFile := fpath.Join(state.destDir, img.Path)
+	state.out.mkdir(fpath.Dir(destFile))
+	return img, state.out.copyFile(cacheFile, destFile)
+}
+
+// tells whether target is missing or older than source
+func isStale(target, source string) bool {
+	tinfo, err := os.Stat(target)
+	if err != nil {
+		return true
+	}
+	sinfo, err := os.Stat(source)
+	if err != nil {
+		return true
+	}
+	return tinfo.ModTime().Before(sinfo.ModTime())
+}
+
+// returns a srcset of images with the given widths, such as
+// "cat_400x0_fit_q85.jpg 400w, cat_800x0_fit_q85.jpg 800w"
+func imageSrcset(state *gostState, path, widths, spec string, url func(string) string) (string, error) {
+	var entries []string
+	for _, w := range strings.Fields(widths) {
+		img, err := processImage(state, path, w+"x "+spec)
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, fmt.Sprintf("%s %dw", url(img.Path), img.Width))
+	}
+	return strings.Join(entries, ", "), nil
+}
@@ -0,0 +1,238 @@
+package imgproc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nvlled/gost/util"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	fpath "path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	FIT  = "fit"
+	FILL = "fill"
+
+	DEFAULT_QUALITY = 85
+)
+
+// Spec describes a derived image, written as space-separated
+// words such as "300x200 fill png q90":
+//
+//	WxH, Wx, xH   the size, a missing side keeps the aspect ratio
+//	fit           scale to fit inside the size (default)
+//	fill          scale and crop to the exact size
+//	jpg, png      output format, defaults to the format of the source
+//	qN            jpeg quality, 85 by default
+type Spec struct {
+	Width   int
+	Height  int
+	Mode    string
+	Format  string
+	Quality int
+}
+
+func ParseSpec(s string) (Spec, error) {
+	spec := Spec{Mode: FIT, Quality: DEFAULT_QUALITY}
+	for _, word := range strings.Fields(strings.ToLower(s)) {
+		switch {
+		case word == FIT || word == FILL:
+			spec.Mode = word
+		case word == "jpg" || word == "jpeg":
+			spec.Format = "jpeg"
+		case word == "png":
+			spec.Format = "png"
+		case strings.HasPrefix(word, "q"):
+			q, err := strconv.Atoi(word[1:])
+			if err != nil || q < 1 || q > 100 {
+				return spec, errors.New("invalid quality: " + word)
+			}
+			spec.Quality = q
+		case strings.Contains(word, "x"):
+			sub := strings.SplitN(word, "x", 2)
+			var err error
+			if sub[0] != "" {
+				if spec.Width, err = strconv.Atoi(sub[0]); err != nil {
+					return spec, errors.New("invalid size: " + word)
+				}
+			}
+			if sub[1] != "" {
+				if spec.Height, err = strconv.Atoi(sub[1]); err != nil {
+					return spec, errors.New("invalid size: " + word)
+				}
+			}
+		default:
+			return spec, errors.New("unknown image spec: " + word)
+		}
+	}
+	if spec.Width <= 0 && spec.Height <= 0 {
+		return spec, errors.New("missing image size: " + s)
+	}
+	if spec.Mode == FILL && (spec.Width <= 0 || spec.Height <= 0) {
+		return spec, errors.New("fill requires both width and height: " + s)
+	}
+	return spec, nil
+}
+
+// Suffix is added to the filename of derived images
+// given the extension of the source image
+func (spec Spec) Suffix(srcExt string) string {
+	s := fmt.Sprintf("%dx%d_%s", spec.Width, spec.Height, spec.Mode)
+	// the quality only matters for jpeg
+	if spec.Ext(srcExt) == ".jpg" {
+		s += fmt.Sprintf("_q%d", spec.Quality)
+	}
+	return s
+}
+
+func (spec Spec) outputFormat(srcFormat string) string {
+	if spec.Format != "" {
+		return spec.Format
+	}
+	if srcFormat == "jpeg" {
+		return "jpeg"
+	}
+	return "png"
+}
+
+// Ext returns the file extension of the derived image
+// given the extension of the source image
+func (spec Spec) Ext(srcExt string) string {
+	srcFormat := ""
+	if e := strings.ToLower(srcExt); e == ".jpg" || e == ".jpeg" {
+		srcFormat = "jpeg"
+	}
+	if spec.outputFormat(srcFormat) == "jpeg" {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// Size returns the size of the derived image
+// of a source image with the given size
+func (spec Spec) Size(w, h int) (int, int) {
+	if w <= 0 || h <= 0 {
+		return 0, 0
+	}
+	if spec.Mode == FILL {
+		return spec.Width, spec.Height
+	}
+	scale := math.Inf(1)
+	if spec.Width > 0 {
+		scale = float64(spec.Width) / float64(w)
+	}
+	if spec.Height > 0 {
+		scale = math.Min(scale, float64(spec.Height)/float64(h))
+	}
+	return max1(int(math.Round(float64(w) * scale))), max1(int(math.Round(float64(h) * scale)))
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// DecodeSize returns the size of the image in filename
+func DecodeSize(filename string) (int, int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	cfg, _, err := image.DecodeConfig(file)
+	return cfg.Width, cfg.Height, err
+}
+
+// Process writes the image derived from srcFile into destFile
+func Process(srcFile, destFile string, spec Spec) error {
+	file, err := os.Open(srcFile)
+	if err != nil {
+		return err
+	}
+	src, srcFormat, err := image.Decode(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", srcFile, err)
+	}
+
+	bounds := src.Bounds()
+	w, h := spec.Size(bounds.Dx(), bounds.Dy())
+	if spec.Mode == FILL {
+		bounds = cropToAspect(bounds, w, h)
+	}
+	dest := Resize(src, bounds, w, h)
+
+	if err := os.MkdirAll(fpath.Dir(destFile), os.ModeDir|0775); err != nil {
+		return err
+	}
+	out, err := os.Create(destFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if spec.outputFormat(srcFormat) == "jpeg" {
+		return jpeg.Encode(out, dest, &jpeg.Options{Quality: spec.Quality})
+	}
+	return png.Encode(out, dest)
+}
+
+// returns the centered part of r with the aspect ratio of w:h
+func cropToAspect(r image.Rectangle, w, h int) image.Rectangle {
+	cw, ch := r.Dx(), r.Dy()
+	if cw*h > ch*w {
+		cw = ch * w / h
+	} else {
+		ch = cw * h / w
+	}
+	x := r.Min.X + (r.Dx()-cw)/2
+	y := r.Min.Y + (r.Dy()-ch)/2
+	return image.Rect(x, y, x+max1(cw), y+max1(ch))
+}
+
+// Resize scales the part r of src to w x h. Each pixel
+// is the average of the source pixels that it covers.
+func Resize(src image.Image, r image.Rectangle, w, h int) *image.RGBA {
+	rgba := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, r.Min, draw.Src)
+
+	dest := image.NewRGBA(image.Rect(0, 0, w, h))
+	sx := float64(r.Dx()) / float64(w)
+	sy := float64(r.Dy()) / float64(h)
+	for y := 0; y < h; y++ {
+		y0 := int(float64(y) * sy)
+		y1 := util.Max(y0+1, int(math.Ceil(float64(y+1)*sy)))
+		for x := 0; x < w; x++ {
+			x0 := int(float64(x) * sx)
+			x1 := util.Max(x0+1, int(math.Ceil(float64(x+1)*sx)))
+
+			var sum [4]int
+			n := 0
+			for yy := y0; yy < y1 && yy < r.Dy(); yy++ {
+				for xx := x0; xx < x1 && xx < r.Dx(); xx++ {
+					i := rgba.PixOffset(xx, yy)
+					for c := 0; c < 4; c++ {
+						sum[c] += int(rgba.Pix[i+c])
+					}
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			i := dest.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dest.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dest
+}
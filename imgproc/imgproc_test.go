@@ -0,0 +1,72 @@
+package imgproc
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	type rowt struct {
+		spec          string
+		width, height int
+		expectedW     int
+		expectedH     int
+	}
+	testData := []rowt{
+		// spec  source size  expected size
+		{"300x", 1200, 800, 300, 200},
+		{"x100", 1200, 800, 150, 100},
+		{"300x300", 1200, 800, 300, 200},
+		{"300x300 fill", 1200, 800, 300, 300},
+	}
+	for _, row := range testData {
+		spec, err := ParseSpec(row.spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		w, h := spec.Size(row.width, row.height)
+		if w != row.expectedW || h != row.expectedH {
+			t.Error("spec =", row.spec, "| Expected", row.expectedW, row.expectedH, "got", w, h)
+		}
+	}
+}
+
+func TestSuffix(t *testing.T) {
+	testData := [][]string{
+		// spec  source extension  expected filename suffix
+		{"60x", ".png", "60x0_fit.png"},
+		{"60x", ".jpg", "60x0_fit_q85.jpg"},
+		{"60x jpg q70", ".png", "60x0_fit_q70.jpg"},
+		{"60x png", ".JPG", "60x0_fit.png"},
+	}
+	for _, row := range testData {
+		spec, err := ParseSpec(row[0])
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if result := spec.Suffix(row[1]) + spec.Ext(row[1]); result != row[2] {
+			t.Error("spec =", row[0], "| Expected", row[2], "got", result)
+		}
+	}
+}
+
+func TestInvalidSpec(t *testing.T) {
+	for _, s := range []string{"", "fill 300x", "300x q200", "300x blurry"} {
+		if _, err := ParseSpec(s); err == nil {
+			t.Error("Expected error for spec", s)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range src.Pix {
+		src.Pix[i] = 200
+	}
+	dest := Resize(src, src.Bounds(), 2, 2)
+	if dest.Bounds().Dx() != 2 || dest.Pix[0] != 200 {
+		t.Error("Expected a 2x2 image with the same color, got", dest.Bounds(), dest.Pix[:4])
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/nvlled/gost/genv"
+	fpath "path/filepath"
+	"sort"
+	"strings"
+)
+
+func resetIndex() {
+	index = make(Index)
+	pathIndex = make(Index)
+	langIndex = make(map[string]Index)
+	generators = nil
+	generated = make(map[string][]genv.T)
+	relatedCache = make(map[string]map[string][]genv.T)
+	collectionCache = make(map[string][]genv.T)
+	sections = make(map[string]*section)
+	pageSections = make(map[string]*section)
+	renderedContents = make(map[string]string)
+	srcPaths = make(map[string]string)
+	pageFiles = make(map[string]string)
+	prettyPaths = make(map[string]string)
+}
+
+// resets and rebuilds the indices
+func loadIndex(state *gostState) {
+	resetIndex()
+	buildIndex(state, state.srcDir, state.baseEnv)
+	runGenerators(state)
+}
+
+// indexQuery selects itemplates from the pathIndex.
+// An env matches if it contains all the entries in filters
+// and its source or output path is in one of the prefixes.
+// Empty filters or prefixes match everything.
+type indexQuery struct {
+	filters  map[string]string
+	prefixes []string
+	srcDir   string
+}
+
+// parses args of the form key:value as filters,
+// the rest are treated as path prefixes
+func parseIndexQuery(state *gostState, args []string) indexQuery {
+	query := indexQuery{filters: make(map[string]string), srcDir: state.srcDir}
+	for _, arg := range args {
+		sub := strings.SplitN(arg, genv.SEP, 2)
+		if len(sub) == 2 {
+			query.filters[strings.TrimSpace(sub[0])] = strings.TrimSpace(sub[1])
+		} else {
+			query.prefixes = append(query.prefixes, srcRelativePath(state, arg))
+		}
+	}
+	return query
+}
+
+func (query indexQuery) matches(env genv.T) bool {
+	for k, v := range query.filters {
+		if env.Get(k) != v {
+			return false
+		}
+	}
+	if len(query.prefixes) == 0 {
+		return true
+	}
+	// the prefixes are relative to the srcDir, but the
+	// output path is also accepted since it may differ
+	// with languages and pretty-urls
+	path := env.Get("path")
+	srcPath := path
+	if src, ok := srcPaths[path]; ok {
+		// generated pages have the path of their generator
+		src = strings.SplitN(src, "#", 2)[0]
+		srcPath = fpath.Join("/", strings.TrimPrefix(src, query.srcDir))
+	}
+	for _, prefix := range query.prefixes {
+		if hasPathPrefix(srcPath, prefix) || hasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// tells whether path is prefix or is under the directory prefix,
+// so that /articles does not match /articles-old/x.html
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// returns the matching envs, sorted by path
+func (query indexQuery) run() []genv.T {
+	var envs []genv.T
+	for _, env := range pathIndex {
+		if query.matches(env) {
+			envs = append(envs, env)
+		}
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].Get("path") < envs[j].Get("path")
+	})
+	return envs
+}
+
+func indexToJSON(envs []genv.T) ([]byte, error) {
+	entries := []map[string]interface{}{}
+	for _, env := range envs {
+		entries = append(entries, env.Entries())
+	}
+	return json.MarshalIndent(entries, "", "  ")
+}
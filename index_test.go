@@ -0,0 +1,54 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	"strings"
+	"testing"
+)
+
+func TestPathPrefix(t *testing.T) {
+	testData := []struct {
+		path, prefix string
+		expected     bool
+	}{
+		{"/articles/hello.html", "/articles", true},
+		{"/articles/hello.html", "/articles/", true},
+		{"/articles", "/articles", true},
+		{"/articles-old/hello.html", "/articles", false},
+		{"/about.html", "/", true},
+		{"/about.html", "/about.html", true},
+	}
+	for _, row := range testData {
+		if result := hasPathPrefix(row.path, row.prefix); result != row.expected {
+			t.Error("path =", row.path, "prefix =", row.prefix, "| Expected", row.expected, "got", result)
+		}
+	}
+}
+
+func TestIndexQueryPaths(t *testing.T) {
+	resetIndex()
+	defer resetIndex()
+	state := newState("/src/", "/build/")
+	addToIndex(state, "/src/articles/hello.ja.html", genv.Parse("path: /ja/articles/hello.html"))
+	addToIndex(state, "/src/about.html", genv.Parse("path: /about/"))
+	addToIndex(state, "/src/list.html#0", genv.Parse("path: /products/a.html"))
+
+	testData := [][]string{
+		// prefix  expected paths
+		{"/articles/hello.ja.html", "/ja/articles/hello.html"},
+		{"/ja/articles", "/ja/articles/hello.html"},
+		{"/articles/hello.html"},
+		{"/about.html", "/about/"},
+		{"/list.html", "/products/a.html"},
+		{"/products", "/products/a.html"},
+	}
+	for _, row := range testData {
+		var paths []string
+		for _, env := range parseIndexQuery(state, row[:1]).run() {
+			paths = append(paths, env.Get("path"))
+		}
+		if strings.Join(paths, " ") != strings.Join(row[1:], " ") {
+			t.Error("prefix =", row[0], "| Expected", row[1:], "got", paths)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	fpath "path/filepath"
+	"sort"
+	"strings"
+)
+
+// Languages are declared in the base-env, the first
+// one being the default language:
+//
+//   languages: en ja
+//
+// The language of a file is taken from its filename suffix
+// (about.ja.html) or from its top-level directory (ja/about.html),
+// otherwise it is the default language.
+// Files in the default language are built in the root
+// of destDir, the others are built in /<lang>/.
+
+// langIndex maps each language to its index by id
+var langIndex map[string]Index
+
+func (state *gostState) setLanguages(langs []string) *gostState {
+	state.languages = langs
+	return state
+}
+
+func (state *gostState) defaultLang() string {
+	if len(state.languages) == 0 {
+		return ""
+	}
+	return state.languages[0]
+}
+
+func (state *gostState) isLang(s string) bool {
+	for _, lang := range state.languages {
+		if s == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// splits path, relative to srcDir, into its language and
+// the language-neutral path, e.g. about.ja.html -> ja, about.html
+func (state *gostState) splitLang(path string) (string, string) {
+	if len(state.languages) == 0 {
+		return "", path
+	}
+	dir, base := fpath.Split(path)
+	ext := fpath.Ext(base)
+	if suffix := fpath.Ext(strings.TrimSuffix(base, ext)); suffix != "" {
+		if lang := suffix[1:]; state.isLang(lang) {
+			base = strings.TrimSuffix(base, suffix+ext) + ext
+			return lang, fpath.Join(dir, base)
+		}
+	}
+	sub := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(sub) == 2 && state.isLang(sub[0]) {
+		return sub[0], sub[1]
+	}
+	return state.defaultLang(), path
+}
+
+// returns the path, relative to destDir, where
+// the file in path (relative to srcDir) is built
+func (state *gostState) outputPath(path string) string {
+	lang, neutralPath := state.splitLang(path)
+	if lang == state.defaultLang() {
+		return neutralPath
+	}
+	return fpath.Join(lang, neutralPath)
+}
+
+// sets the lang and translation-key of the env of an itemplate
+func (state *gostState) setLangEntries(env genv.T, path string) {
+	if len(state.languages) == 0 {
+		return
+	}
+	lang, neutralPath := state.splitLang(path)
+	env.Set(langKey, lang)
+	if _, ok := env.GetOk(translationKey); !ok {
+		env.Set(translationKey, fpath.Join("/", neutralPath))
+	}
+}
+
+// returns the envs of the other-language versions of env
+func translationsOf(env genv.T) []interface{} {
+	key, ok := env.GetOk(translationKey)
+	if !ok {
+		return nil
+	}
+	lang := env.Get(langKey)
+	var envs []genv.T
+	for _, other := range pathIndex {
+		if other.Get(translationKey) == key && other.Get(langKey) != lang {
+			envs = append(envs, other)
+		}
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].Get(langKey) < envs[j].Get(langKey)
+	})
+	var result []interface{}
+	for _, env := range envs {
+		result = append(result, env.Entries())
+	}
+	return result
+}
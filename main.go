@@ -23,13 +23,44 @@ const (
 	includesKey = recenvPrefix + "includes-dir"
 	layoutsKey  = recenvPrefix + "layouts-dir"
 	protosKey   = recenvPrefix + "protos-dir"
+	themeKey    = recenvPrefix + "theme"
 	verbatimKey = recenvPrefix + "verbatim-files"
 	excludesKey = recenvPrefix + "exclude-files"
 
+	highlightKey  = recenvPrefix + "highlight-code"
+	tocDepthKey   = recenvPrefix + "toc-depth"
+	imageCacheKey = recenvPrefix + "image-cache"
+
+	generateKey     = recenvPrefix + "generate"
+	generatePathKey = recenvPrefix + "generate-path"
+	generateIdKey   = recenvPrefix + "generate-id"
+
+	languagesKey   = recenvPrefix + "languages"
+	langKey        = recenvPrefix + "lang"
+	translationKey = recenvPrefix + "translation-key"
+
+	timeZoneKey = recenvPrefix + "time-zone"
+	dateKey     = recenvPrefix + "date"
+	baseURLKey  = recenvPrefix + "base-url"
+
+	prettyURLsKey = recenvPrefix + "pretty-urls"
+
+	aliasesKey       = recenvPrefix + "aliases"
+	redirectsFileKey = recenvPrefix + "redirects-file"
+
+	searchIndexKey   = recenvPrefix + "search-index"
+	searchQueryKey   = recenvPrefix + "search-query"
+	searchFieldsKey  = recenvPrefix + "search-fields"
+	searchExcludeKey = recenvPrefix + "search-exclude"
+	searchTermsKey   = recenvPrefix + "search-terms"
+
 	protoOpenDelim  = "[["
 	protoCloseDelim = "]]"
 )
 
+// source of the env entries given with -env
+const cliEnvSource = "-env flag"
+
 var verbose bool
 var defaultOptsfile = "gostopts"
 
@@ -38,6 +69,7 @@ var defaultOpts = func() *gostOpts {
 	emptyStr := ""
 	true_ := true
 	false_ := false
+	one := 1
 	return &gostOpts{
 		srcDir:   &emptyStr,
 		destDir:  &emptyStr,
@@ -45,6 +77,15 @@ var defaultOpts = func() *gostOpts {
 		help:     &false_,
 		verbose:  &true_,
 		env:      &emptyStr,
+		dryRun:   &false_,
+		theme:    &emptyStr,
+
+		buildTime: &emptyStr,
+		preBuild:  &emptyStr,
+		postBuild: &emptyStr,
+		profile:   &emptyStr,
+		baseURL:   &emptyStr,
+		jobs:      &one,
 	}
 }()
 
@@ -56,6 +97,7 @@ var defaultVerbatimList = []predicate{}
 var defaultExcludesList = []predicate{
 	isDotFile,
 	baseIs(MARKER_NAME),
+	baseIs(OUTPUTS_NAME),
 	baseIs(genv.FILENAME),
 	dirIsVar("includesDir"),
 	dirIsVar("layoutsDir"),
@@ -72,6 +114,8 @@ func usage(prog string, flagSet *flag.FlagSet) {
 		println(indent, name)
 	}
 	println("options:")
+	// parseArgs discards the output of the flagSet
+	flagSet.SetOutput(os.Stderr)
 	flagSet.PrintDefaults()
 	println("action help:")
 	println(indent, "Specify both -help and the action to show help for each action")
@@ -84,44 +128,70 @@ func printLog(args ...interface{}) {
 	}
 }
 
+// reports an error in the options or the base-env,
+// which is not worth a stack trace, and exits
+func exitOnError(err error) {
+	if err != nil {
+		println("***", err.Error())
+		os.Exit(1)
+	}
+}
+
 func main() {
 	prog := os.Args[0]
-	fileOpts := new(gostOpts)
-	cliOpts, flagSet := parseArgs(os.Args[1:], defaultOpts)
+	var file *optsFile
+	cliOpts, flagSet, err := parseArgs(os.Args[1:], defaultOpts, true)
+	if err == flag.ErrHelp {
+		usage(prog, flagSet)
+		return
+	} else if err != nil {
+		println("***", err.Error())
+		usage(prog, flagSet)
+		os.Exit(2)
+	}
+
+	envOpts, err := readEnvOpts(os.LookupEnv, defaultOpts)
+	if err != nil {
+		println("***", err.Error())
+		os.Exit(2)
+	}
+	// the options file and profile may be selected by both
+	selectOpts := envOpts.merge(cliOpts)
 
 	createOpts := func() *gostOpts {
 
 		// srcDir and destDir are relative to dir of optsfile
 		baseDir, _ := filepath.Abs(".")
 
-		if cliOpts.optsfile != nil {
-			baseDir = path.Dir(*cliOpts.optsfile)
-			opts, err := readOptsFile(*cliOpts.optsfile, defaultOpts)
-			if err != nil {
-				println("*** no gostopts file found")
-				//println(err.Error())
-				//return nil
-			}
-			fileOpts = opts
+		var err error
+		if selectOpts.optsfile != nil {
+			baseDir = path.Dir(*selectOpts.optsfile)
+			file, err = readOptsFile(*selectOpts.optsfile, defaultOpts)
 		} else {
-			opts, err := readOptsFile(*defaultOpts.optsfile, defaultOpts)
-			if err != nil {
+			file, err = readOptsFile(*defaultOpts.optsfile, defaultOpts)
+			if os.IsNotExist(err) {
 				baseDir = path.Dir(baseDir)
 				optsfile := path.Join(baseDir, *defaultOpts.optsfile)
-				opts, err = readOptsFile(optsfile, defaultOpts)
-			}
-			if err != nil {
-				println("*** no gostopts file found")
-				//println(err.Error())
-				//return nil
+				file, err = readOptsFile(optsfile, defaultOpts)
 			}
+		}
+		if os.IsNotExist(err) {
+			println("*** no gostopts file found")
+		} else if err != nil {
+			println("***", err.Error())
+			os.Exit(1)
+		}
 
-			fileOpts = opts
+		// defaults < options file < profile < environment < commandline
+		fileOpts, err := file.withProfile(selectOpts.profile)
+		if err != nil {
+			println("*** " + err.Error())
+			os.Exit(1)
 		}
-		opts := defaultOpts.merge(fileOpts).merge(cliOpts)
+		opts := defaultOpts.merge(fileOpts).merge(envOpts).merge(cliOpts)
 
 		prependBase := func(dir string) *string {
-			if dir == "" {
+			if dir == "" || filepath.IsAbs(dir) {
 				// avoid returning "." when dir is ""
 				return &dir
 			}
@@ -131,6 +201,7 @@ func main() {
 		}
 		opts.srcDir = prependBase(*opts.srcDir)
 		opts.destDir = prependBase(*opts.destDir)
+		opts.theme = prependBase(*opts.theme)
 
 		return opts
 	}
@@ -170,17 +241,47 @@ func optsToState(opts *gostOpts) *gostState {
 	srcDir := util.AddTrailingSlash(*opts.srcDir)
 	destDir := util.AddTrailingSlash(*opts.destDir)
 	state := newState(srcDir, destDir)
+	state.setOutput(newOutput(*opts.dryRun))
+	state.setJobs(*opts.jobs)
 
 	// envs specified in the command line takes priority over
 	// the baseEnv (the env file in the src directory).
 	fileEnv := genv.ReadDir(*opts.srcDir)
 	env := genv.Parse(strings.Replace(*opts.env, ";", "\n", -1))
+	env.SetSource(cliEnvSource)
 	env.SetParent(fileEnv)
+	exitOnError(envCycleError(env))
+	if *opts.baseURL != "" {
+		env.Set(baseURLKey, *opts.baseURL)
+	}
+	_, err := parseBaseURL(env.Get(baseURLKey))
+	exitOnError(err)
 
 	state.baseEnv = env
 	state.setIncludesDir(env.GetOr(includesKey, defaultIncludesDir))
 	state.setLayoutsDir(env.GetOr(layoutsKey, defaultLayoutsDir))
 	state.setProtosDir(env.GetOr(protosKey, defaultProtosDir))
+	state.setImageCacheDir(env.GetOr(imageCacheKey, defaultImageCacheDir))
+	exitOnError(initTime(env.Get(timeZoneKey), *opts.buildTime))
+
+	// theme in the base-env is relative to srcDir
+	themeDir := *opts.theme
+	if dir := env.Get(themeKey); themeDir == "" && dir != "" {
+		themeDir = util.PrependPath(dir, srcDir)
+	}
+	state.setThemes(loadThemes(themeDir))
+
+	// hooks in the options take priority
+	for name, command := range map[string]string{
+		preBuildHook:  *opts.preBuild,
+		postBuildHook: *opts.postBuild,
+	} {
+		if command == "" {
+			command = env.Get(name)
+		}
+		state.setHook(name, command)
+	}
+	state.setLanguages(strings.Fields(env.Get(languagesKey)))
 
 	fn := func(name string) []string {
 		paths := strings.Fields(env.Get(name))
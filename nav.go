@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nvlled/gost/genv"
+	"sort"
+	"strings"
+)
+
+// A collection is the list of itemplates selected by an
+// index query, such as "category: article", sorted by an
+// env entry. A sort key starting with - sorts in descending order.
+// prev and next return the neighbors of a page in its collection.
+
+// sorted collections, computed once per build
+// for each language, query and sort key
+var collectionCache map[string][]genv.T
+
+func collection(state *gostState, lang, query, sortKey string) ([]genv.T, error) {
+	cacheKey := lang + "\x00" + query + "\x00" + sortKey
+	if envs, ok := collectionCache[cacheKey]; ok {
+		return envs, nil
+	}
+	args, err := splitQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	var envs []genv.T
+	for _, env := range parseIndexQuery(state, args).run() {
+		if env.Get(langKey) == lang {
+			envs = append(envs, env)
+		}
+	}
+	desc := strings.HasPrefix(sortKey, "-")
+	sortKey = strings.TrimPrefix(sortKey, "-")
+	// the envs are already sorted by path,
+	// which is kept for equal values
+	sort.SliceStable(envs, func(i, j int) bool {
+		if desc {
+			return compareValues(envs[j].Get(sortKey), envs[i].Get(sortKey)) < 0
+		}
+		return compareValues(envs[i].Get(sortKey), envs[j].Get(sortKey)) < 0
+	})
+	collectionCache[cacheKey] = envs
+	return envs, nil
+}
+
+// splits a query into args for parseIndexQuery,
+// allowing a space after the colon: "category: article".
+// Values with spaces are quoted as in the options file:
+// "category: 'my notes'"
+func splitQuery(query string) ([]string, error) {
+	words, err := splitArgs(query)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %v", query, err)
+	}
+	var args []string
+	for _, word := range words {
+		if n := len(args); n > 0 && strings.HasSuffix(args[n-1], genv.SEP) {
+			args[n-1] += word
+		} else {
+			args = append(args, word)
+		}
+	}
+	return args, nil
+}
+
+// kinds of values, in the order in which they are sorted
+const (
+	numberValue = iota
+	dateValue
+	stringValue
+)
+
+func valueKind(s string) int {
+	if _, _, _, err := toNumber(s); err == nil {
+		return numberValue
+	}
+	if _, err := parseDate(s); err == nil {
+		return dateValue
+	}
+	return stringValue
+}
+
+// compares numbers as numbers, dates as dates, and everything
+// else as strings. Values of different kinds are ordered
+// numbers first, then dates, then strings.
+func compareValues(a, b string) int {
+	ka, kb := valueKind(a), valueKind(b)
+	switch {
+	case ka < kb:
+		return -1
+	case ka > kb:
+		return 1
+	case ka == numberValue:
+		_, x, _, _ := toNumber(a)
+		_, y, _, _ := toNumber(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case ka == dateValue:
+		ta, _ := parseDate(a)
+		tb, _ := parseDate(b)
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// returns the env at offset from the page in its collection,
+// or nil if there is none
+func neighbor(state *gostState, env genv.T, query, sortKey string, offset int) (genv.T, error) {
+	envs, err := collection(state, env.Get(langKey), query, sortKey)
+	if err != nil {
+		return nil, err
+	}
+	path := env.Get("path")
+	for i, other := range envs {
+		if other.Get("path") == path {
+			if j := i + offset; j >= 0 && j < len(envs) {
+				return envs[j], nil
+			}
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitQuery(t *testing.T) {
+	testData := [][]string{
+		// query  expected args, separated by |
+		{"category: article /notes", "category:article|/notes"},
+		{`section: "my notes" '/my notes'`, "section:my notes|/my notes"},
+		{"section:'my notes'", "section:my notes"},
+	}
+	for _, row := range testData {
+		args, err := splitQuery(row[0])
+		if result := strings.Join(args, "|"); err != nil || result != row[1] {
+			t.Error("query =", row[0], "| Expected", row[1], "got", result, err)
+		}
+	}
+	if _, err := splitQuery("section: 'my notes"); err == nil {
+		t.Error("Expected an error for a missing quote")
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	// sorted values, numbers first, then dates, then strings
+	values := []string{"-1", "2", "10", "2020-01-01", "2021-06-01", "10a", "apple", "b"}
+	for i, a := range values {
+		for j, b := range values {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			if result := compareValues(a, b); result != expected {
+				t.Error("compare", a, b, "| Expected", expected, "got", result)
+			}
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"strings"
 	"unicode"
 )
@@ -16,6 +19,15 @@ type gostOpts struct {
 	help     *bool
 	verbose  *bool
 	env      *string
+	dryRun   *bool
+	theme    *string
+
+	buildTime *string
+	preBuild  *string
+	postBuild *string
+	profile   *string
+	baseURL   *string
+	jobs      *int
 }
 
 // * merges opts and opts_
@@ -49,11 +61,39 @@ func (opts *gostOpts) merge(opts_ *gostOpts) *gostOpts {
 	if opts_.env != nil {
 		newOpts.env = opts_.env
 	}
+	if opts_.dryRun != nil {
+		newOpts.dryRun = opts_.dryRun
+	}
+	if opts_.theme != nil {
+		newOpts.theme = opts_.theme
+	}
+	if opts_.buildTime != nil {
+		newOpts.buildTime = opts_.buildTime
+	}
+	if opts_.preBuild != nil {
+		newOpts.preBuild = opts_.preBuild
+	}
+	if opts_.postBuild != nil {
+		newOpts.postBuild = opts_.postBuild
+	}
+	if opts_.profile != nil {
+		newOpts.profile = opts_.profile
+	}
+	if opts_.baseURL != nil {
+		newOpts.baseURL = opts_.baseURL
+	}
+	if opts_.jobs != nil {
+		newOpts.jobs = opts_.jobs
+	}
 	return &newOpts
 }
 
-func parseArgs(args []string, defaults *gostOpts) (*gostOpts, *flag.FlagSet) {
-	flagSet := flag.NewFlagSet("flags", flag.ExitOnError)
+// errors are returned instead of being printed, unknown
+// options and arguments after the options are errors
+// if allowArgs is false
+func parseArgs(args []string, defaults *gostOpts, allowArgs bool) (*gostOpts, *flag.FlagSet, error) {
+	flagSet := flag.NewFlagSet("flags", flag.ContinueOnError)
+	flagSet.SetOutput(ioutil.Discard)
 
 	srcDir := flagSet.String("srcDir", *defaults.srcDir, "source files")
 	destDir := flagSet.String("destDir", *defaults.destDir, "destination files")
@@ -61,8 +101,21 @@ func parseArgs(args []string, defaults *gostOpts) (*gostOpts, *flag.FlagSet) {
 	help := flagSet.Bool("help", *defaults.help, "show help")
 	verbose := flagSet.Bool("verbose", *defaults.verbose, "show verbose output")
 	env := flagSet.String("env", *defaults.env, "add base-env entries")
+	theme := flagSet.String("theme", *defaults.theme, "theme directory")
+	dryRun := flagSet.Bool("dry-run", *defaults.dryRun, "show what would be written without touching the filesystem")
+	buildTime := flagSet.String("build-time", *defaults.buildTime, "fixed build time, a unix timestamp or a date")
+	preBuild := flagSet.String("pre-build", *defaults.preBuild, "command to run before each build")
+	postBuild := flagSet.String("post-build", *defaults.postBuild, "command to run after each build")
+	profile := flagSet.String("profile", *defaults.profile, "profile in the opts file")
+	baseURL := flagSet.String("base-url", *defaults.baseURL, "url of the site, sets the base-url entry of the base-env")
+	jobs := flagSet.Int("jobs", *defaults.jobs, "number of files copied at the same time")
 
-	flagSet.Parse(args)
+	if err := flagSet.Parse(args); err != nil {
+		return nil, flagSet, err
+	}
+	if !allowArgs && flagSet.NArg() > 0 {
+		return nil, flagSet, errors.New("unexpected argument: " + flagSet.Arg(0))
+	}
 
 	// opts will have nil values for flags that are not set
 	opts := &gostOpts{}
@@ -80,12 +133,76 @@ func parseArgs(args []string, defaults *gostOpts) (*gostOpts, *flag.FlagSet) {
 			opts.verbose = verbose
 		case "env":
 			opts.env = env
+		case "dry-run":
+			opts.dryRun = dryRun
+		case "theme":
+			opts.theme = theme
+		case "build-time":
+			opts.buildTime = buildTime
+		case "pre-build":
+			opts.preBuild = preBuild
+		case "post-build":
+			opts.postBuild = postBuild
+		case "profile":
+			opts.profile = profile
+		case "base-url":
+			opts.baseURL = baseURL
+		case "jobs":
+			opts.jobs = jobs
 		}
 	})
-	return opts, flagSet
+	return opts, flagSet, nil
 }
 
-func readOptsFile(filename string, defaultOpts *gostOpts) (*gostOpts, error) {
+// Each option may also be given by an environment variable
+// named GOST_ followed by the name of the option in uppercase,
+// with - replaced by _, e.g. GOST_SRCDIR or GOST_DRY_RUN.
+const envVarPrefix = "GOST_"
+
+func envVarName(option string) string {
+	return envVarPrefix + strings.ToUpper(strings.Replace(option, "-", "_", -1))
+}
+
+// reads the options from the environment variables given by lookup
+func readEnvOpts(lookup func(string) (string, bool), defaults *gostOpts) (*gostOpts, error) {
+	_, flagSet, _ := parseArgs(nil, defaults, false)
+	opts := &gostOpts{}
+	var err error
+	flagSet.VisitAll(func(f *flag.Flag) {
+		name := envVarName(f.Name)
+		value, ok := lookup(name)
+		if !ok || err != nil {
+			return
+		}
+		var opts_ *gostOpts
+		opts_, _, err = parseArgs([]string{"-" + f.Name + "=" + value}, defaults, false)
+		if err != nil {
+			err = fmt.Errorf("%s: %v", name, err)
+			return
+		}
+		opts = opts.merge(opts_)
+	})
+	return opts, err
+}
+
+// The options file has the same options as the commandline,
+// and may have profiles, which are sections that start
+// with the name of the profile in brackets:
+//
+//	# comments start with #
+//	--srcDir src --destDir "my site"
+//	[prod]
+//	--destDir public -env 'relative-url: false; sitename: My Site'
+//
+// The options before the first profile apply to all profiles.
+type optsFile struct {
+	opts     *gostOpts
+	profiles map[string]*gostOpts
+}
+
+var profileRe = regexp.MustCompile(`^\s*\[([\w.-]+)\]\s*(#.*)?$`)
+
+func readOptsFile(filename string, defaultOpts *gostOpts) (*optsFile, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, err
@@ -94,7 +211,119 @@ func readOptsFile(filename string, defaultOpts *gostOpts) (*gostOpts, error) {
 	if err != nil {
 		return nil, err
 	}
-	args := strings.FieldsFunc(string(bytes), unicode.IsSpace)
-	opts, _ := parseArgs(args, defaultOpts)
+	optsFile, err := parseOptsFile(string(bytes), defaultOpts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return optsFile, nil
+}
+
+func parseOptsFile(s string, defaultOpts *gostOpts) (*optsFile, error) {
+	sections := map[string]string{"": ""}
+	name := ""
+	for _, line := range strings.Split(s, "\n") {
+		if m := profileRe.FindStringSubmatch(line); m != nil {
+			name = m[1]
+			continue
+		}
+		sections[name] += line + "\n"
+	}
+	file := &optsFile{profiles: make(map[string]*gostOpts)}
+	for name, section := range sections {
+		args, err := splitArgs(section)
+		if err == nil {
+			var opts *gostOpts
+			opts, _, err = parseArgs(args, defaultOpts, false)
+			if name == "" {
+				file.opts = opts
+			} else {
+				file.profiles[name] = opts
+			}
+		}
+		if err != nil && name != "" {
+			return nil, fmt.Errorf("[%s]: %v", name, err)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// splits s into arguments as in the shell: arguments are
+// separated by spaces unless they are in single or double quotes,
+// a backslash escapes the next character except in single quotes,
+// and a # at the start of an argument starts a comment
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	inComment := false
+	for _, c := range s {
+		switch {
+		case inComment:
+			inComment = c != '\n'
+		case escaped:
+			arg.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote, inArg = c, true
+		case unicode.IsSpace(c):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case c == '#' && !inArg:
+			inComment = true
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("missing closing quote %c", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// returns the options of the file with those of the profile,
+// which is given by the commandline or else by the file.
+// The env entries of the profile are added to the ones of the file.
+func (file *optsFile) withProfile(cliProfile *string) (*gostOpts, error) {
+	name := ""
+	if cliProfile != nil {
+		name = *cliProfile
+	} else if file != nil && file.opts.profile != nil {
+		name = *file.opts.profile
+	}
+	if name == "" {
+		if file == nil {
+			return nil, nil
+		}
+		return file.opts, nil
+	}
+	if file == nil {
+		return nil, errors.New("unknown profile: " + name)
+	}
+	profile, ok := file.profiles[name]
+	if !ok {
+		return nil, errors.New("unknown profile: " + name)
+	}
+	opts := file.opts.merge(profile)
+	if file.opts.env != nil && profile.env != nil {
+		env := *file.opts.env + ";" + *profile.env
+		opts.env = &env
+	}
 	return opts, nil
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	testData := []struct {
+		input    string
+		expected []string
+	}{
+		{"--srcDir src\n--destDir build", []string{"--srcDir", "src", "--destDir", "build"}},
+		{`-destDir "my site" -env 'a: 1; b: "2"'`, []string{"-destDir", "my site", "-env", `a: 1; b: "2"`}},
+		{"# comment\n-theme x # another\n-env a#b", []string{"-theme", "x", "-env", "a#b"}},
+		{`-env a\ b "c\"d"`, []string{"-env", "a b", `c"d`}},
+	}
+	for _, row := range testData {
+		result, err := splitArgs(row.input)
+		if err != nil || !reflect.DeepEqual(result, row.expected) {
+			t.Errorf("input = %q | Expected %q got %q %v", row.input, row.expected, result, err)
+		}
+	}
+	if _, err := splitArgs(`-env "a`); err == nil {
+		t.Error("Expected an error for a missing quote")
+	}
+}
+
+func TestOptsFileProfiles(t *testing.T) {
+	file, err := parseOptsFile("--destDir build -env a:1\n[prod] # deployed\n--destDir public -env b:2", defaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod := "prod"
+	opts, err := file.withProfile(&prod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *opts.destDir != "public" || *opts.env != "a:1;b:2" {
+		t.Error("Expected public and a:1;b:2 got", *opts.destDir, *opts.env)
+	}
+	if _, err := parseOptsFile("-unknown x", defaultOpts); err == nil {
+		t.Error("Expected an error for an unknown option")
+	}
+}
+
+func TestEnvOpts(t *testing.T) {
+	vars := map[string]string{
+		"GOST_DESTDIR": "public",
+		"GOST_DRY_RUN": "1",
+		"GOST_JOBS":    "4",
+		"GOST_OTHER":   "x",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	opts, err := readEnvOpts(lookup, defaultOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *opts.destDir != "public" || !*opts.dryRun || *opts.jobs != 4 || opts.srcDir != nil {
+		t.Error("Unexpected options", *opts.destDir, *opts.dryRun, *opts.jobs, opts.srcDir)
+	}
+	vars["GOST_JOBS"] = "many"
+	if _, err := readEnvOpts(lookup, defaultOpts); err == nil {
+		t.Error("Expected an error for GOST_JOBS=many")
+	}
+}
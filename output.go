@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nvlled/gost/util"
+	"io"
+	"io/ioutil"
+	"os"
+	fpath "path/filepath"
+	"strings"
+	"sync"
+)
+
+// output is the layer through which build, clean and newfile
+// write to the filesystem. In dry-run mode, nothing is written;
+// each operation is only reported in the stdout.
+type output struct {
+	dryRun bool
+
+	// paths that would have been removed or created
+	// in dry-run mode, used for reporting overwrites
+	removed []string
+	created map[string]bool
+
+	// files written, including in dry-run mode,
+	// which may be written by several goroutines
+	mutex   sync.Mutex
+	written map[string]bool
+}
+
+func newOutput(dryRun bool) *output {
+	return &output{
+		dryRun:  dryRun,
+		created: make(map[string]bool),
+		written: make(map[string]bool),
+	}
+}
+
+func (out *output) report(args ...interface{}) {
+	fmt.Println(append([]interface{}{"[dry-run]"}, args...)...)
+}
+
+// exists tells whether path exists, taking into
+// account the removals done in dry-run mode
+func (out *output) exists(path string) bool {
+	path = fpath.Clean(path)
+	if out.created[path] {
+		return true
+	}
+	for _, dir := range out.removed {
+		if path == dir || strings.HasPrefix(path, dir+string(fpath.Separator)) {
+			return false
+		}
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// removes dir if it is empty, and tells whether it was removed.
+// Nothing is removed in dry-run mode.
+func (out *output) removeEmptyDir(dir string) bool {
+	if out.dryRun || os.Remove(dir) != nil {
+		return false
+	}
+	printLog("removing", dir)
+	return true
+}
+
+func (out *output) mkdir(dir string) {
+	if !out.dryRun {
+		util.Mkdir(dir)
+		return
+	}
+	if !out.exists(dir) {
+		out.report("mkdir", dir)
+		out.created[fpath.Clean(dir)] = true
+	}
+}
+
+func (out *output) removeAll(path string) {
+	if !out.dryRun {
+		printLog("removing", path)
+		os.RemoveAll(path)
+		return
+	}
+	out.report("delete", path)
+	out.removed = append(out.removed, fpath.Clean(path))
+}
+
+func (out *output) wrote(path string) {
+	out.mutex.Lock()
+	out.written[fpath.Clean(path)] = true
+	out.mutex.Unlock()
+}
+
+func (out *output) writeFile(destPath string, data []byte) error {
+	out.wrote(destPath)
+	if !out.dryRun {
+		return ioutil.WriteFile(destPath, data, 0644)
+	}
+	out.reportWrite("write", "", destPath)
+	return nil
+}
+
+func (out *output) render(srcPath, destPath, contents string) error {
+	out.wrote(destPath)
+	if !out.dryRun {
+		printLog("rendering", srcPath, "->", destPath)
+		return ioutil.WriteFile(destPath, []byte(contents), 0644)
+	}
+	out.reportWrite("render", srcPath, destPath)
+	return nil
+}
+
+func (out *output) copyFile(srcPath, destPath string) error {
+	out.wrote(destPath)
+	if !out.dryRun {
+		printLog("copying", srcPath, "->", destPath)
+		return util.CopyFile(destPath, srcPath)
+	}
+	out.reportWrite("copy", srcPath, destPath)
+	return nil
+}
+
+// create returns a writer for a new file.
+// In dry-run mode, the writer is the stdout.
+func (out *output) create(path string) (io.WriteCloser, error) {
+	out.wrote(path)
+	if !out.dryRun {
+		return os.Create(path)
+	}
+	out.reportWrite("create", "", path)
+	return nopCloser{os.Stdout}, nil
+}
+
+func (out *output) reportWrite(op, srcPath, destPath string) {
+	args := []interface{}{op}
+	if srcPath != "" {
+		args = append(args, srcPath, "->")
+	}
+	args = append(args, destPath)
+	if out.exists(destPath) {
+		args = append(args, "(overwrite)")
+	}
+	out.report(args...)
+	out.created[fpath.Clean(destPath)] = true
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	"sort"
+	"strings"
+)
+
+// Pages are related if they share terms in the given keys,
+// such as tags or category. Terms are the space-separated
+// words of the values:
+//
+//   tags: go templates
+//
+// Related pages are ranked by the number of shared terms,
+// then by date, newest first.
+
+// ranked related pages by path, computed once per build
+// for each language and set of keys
+var relatedCache map[string]map[string][]genv.T
+
+func relatedPages(env genv.T, n int, keys []string) []genv.T {
+	lang := env.Get(langKey)
+	cacheKey := lang + "\x00" + strings.Join(keys, " ")
+	ranked, ok := relatedCache[cacheKey]
+	if !ok {
+		// pages without an id are related too
+		query := indexQuery{filters: map[string]string{langKey: lang}}
+		ranked = rankRelated(query.run(), keys)
+		relatedCache[cacheKey] = ranked
+	}
+	pages := ranked[env.Get("path")]
+	if n >= 0 && n < len(pages) {
+		pages = pages[:n]
+	}
+	return pages
+}
+
+func termsOf(env genv.T, keys []string) []string {
+	var terms []string
+	for _, key := range keys {
+		for _, word := range strings.Fields(strings.ToLower(env.Get(key))) {
+			terms = append(terms, key+":"+word)
+		}
+	}
+	return terms
+}
+
+// ranks the related pages of each of the given pages
+func rankRelated(envs []genv.T, keys []string) map[string][]genv.T {
+	pages := make(map[string][]genv.T)
+	for _, env := range envs {
+		for _, term := range termsOf(env, keys) {
+			pages[term] = append(pages[term], env)
+		}
+	}
+
+	ranked := make(map[string][]genv.T)
+	for _, env := range envs {
+		path := env.Get("path")
+		scores := make(map[genv.T]int)
+		for _, term := range uniqStrings(termsOf(env, keys)) {
+			for _, other := range pages[term] {
+				if other.Get("path") != path {
+					scores[other]++
+				}
+			}
+		}
+		var related []genv.T
+		for other := range scores {
+			related = append(related, other)
+		}
+		sort.Slice(related, func(i, j int) bool {
+			a, b := related[i], related[j]
+			if scores[a] != scores[b] {
+				return scores[a] > scores[b]
+			}
+			if da, db := pageDate(a), pageDate(b); da != db {
+				return da > db
+			}
+			return a.Get("path") < b.Get("path")
+		})
+		ranked[path] = related
+	}
+	return ranked
+}
+
+// the date entry as a sortable string, empty if invalid
+func pageDate(env genv.T) string {
+	t, err := toTime(env.Get(dateKey))
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02T15:04:05")
+}
+
+func uniqStrings(list []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	"strings"
+	"testing"
+)
+
+func TestRelatedPages(t *testing.T) {
+	resetIndex()
+	defer resetIndex()
+	envs := map[string]string{
+		"a": "path: /a.html\ntags: go web",
+		"b": "path: /b.html\ntags: go web\nid: b",
+		"c": "path: /c.html\ntags: go",
+		"d": "path: /d.html\ntags: rust",
+	}
+	for srcPath, s := range envs {
+		pathIndex[srcPath] = genv.Parse(s)
+	}
+	testData := [][]string{
+		// page  expected related paths
+		{"a", "/b.html", "/c.html"},
+		{"c", "/a.html", "/b.html"},
+		{"d"},
+	}
+	for _, row := range testData {
+		related := relatedPages(pathIndex[row[0]], -1, []string{"tags"})
+		var paths []string
+		for _, env := range related {
+			paths = append(paths, env.Get("path"))
+		}
+		if strings.Join(paths, " ") != strings.Join(row[1:], " ") {
+			t.Error("page =", row[0], "| Expected", row[1:], "got", paths)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	fpath "path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A search index is written if the base-env has a search-index entry:
+//
+//   search-index: search.json          output path, relative to destDir
+//   search-query: category: article    pages to include, all html pages by default
+//   search-fields: title tags date     env entries to include, title by default
+//   search-exclude: /drafts /404.html  output paths to exclude, by prefix
+//   search-terms: search-terms.json    also write an inverted index
+//
+// The search index is a JSON array with an object for each page,
+// containing its path, the fields and the content in plain text.
+// The inverted index maps each word to the positions of the
+// pages in the search index that contain it.
+
+// rendered html of the pages before the layout is
+// applied, by path of the env
+var renderedContents map[string]string
+
+var scriptRe = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+
+// converts html into plain text
+func stripTags(s string) string {
+	s = scriptRe.ReplaceAllString(s, " ")
+	s = tagRe.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func searchEntries(state *gostState) ([]map[string]interface{}, error) {
+	env := state.baseEnv
+	fields := strings.Fields(env.GetOr(searchFieldsKey, "title"))
+	excludes := strings.Fields(env.Get(searchExcludeKey))
+	args, err := splitQuery(env.Get(searchQueryKey))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []map[string]interface{}
+	for _, page := range parseIndexQuery(state, args).run() {
+		path := page.Get("path")
+		contents, ok := renderedContents[path]
+		if !ok || hasAnyPrefix(path, excludes) {
+			continue
+		}
+		entry := map[string]interface{}{
+			"path":    path,
+			"content": stripTags(contents),
+		}
+		for _, key := range fields {
+			entry[key] = page.Get(key)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// tells whether path is in one of the prefixes, see hasPathPrefix
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if hasPathPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// maps each word in the entries to the positions of the entries
+func invertedIndex(entries []map[string]interface{}) map[string][]int {
+	terms := make(map[string][]int)
+	for i, entry := range entries {
+		var keys []string
+		for k := range entry {
+			if k != "path" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, word := range searchWords(toString(entry[k])) {
+				positions := terms[word]
+				if n := len(positions); n == 0 || positions[n-1] != i {
+					terms[word] = append(positions, i)
+				}
+			}
+		}
+	}
+	return terms
+}
+
+func writeSearchIndex(state *gostState) error {
+	filename := state.baseEnv.Get(searchIndexKey)
+	if filename == "" {
+		return nil
+	}
+	entries, err := searchEntries(state)
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = []map[string]interface{}{}
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	printLog("writing search index", filename)
+	if err := writeDestFile(state, filename, data); err != nil {
+		return err
+	}
+
+	filename = state.baseEnv.Get(searchTermsKey)
+	if filename == "" {
+		return nil
+	}
+	data, err = json.Marshal(invertedIndex(entries))
+	if err != nil {
+		return err
+	}
+	printLog("writing search terms", filename)
+	return writeDestFile(state, filename, data)
+}
+
+// writes a file given by its path relative to destDir
+func writeDestFile(state *gostState, filename string, data []byte) error {
+	destPath := fpath.Join(state.destDir, filename)
+	state.out.mkdir(fpath.Dir(destPath))
+	return state.out.writeFile(destPath, data)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSearchExclude(t *testing.T) {
+	excludes := []string{"/drafts", "/404.html"}
+	testData := []struct {
+		path     string
+		expected bool
+	}{
+		{"/drafts/a.html", true},
+		{"/drafts-old/a.html", false},
+		{"/404.html", true},
+		{"/404.html.bak", false},
+	}
+	for _, row := range testData {
+		if result := hasAnyPrefix(row.path, excludes); result != row.expected {
+			t.Error("path =", row.path, "| Expected", row.expected, "got", result)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	fpath "path/filepath"
+	"sort"
+	"strings"
+)
+
+// Each directory in the srcDir is a section, with the env
+// of the directory and the itemplates in it. The index.html
+// of the directory, if any, is the index page of the section.
+
+type section struct {
+	path     string // relative to srcDir, e.g. /articles
+	env      genv.T
+	parent   *section
+	children []*section
+	pages    []genv.T
+	indexes  map[string]genv.T // index pages by language
+}
+
+// sections by path, filled by buildIndex
+var sections map[string]*section
+
+// sections of the itemplates, by path of the env
+var pageSections map[string]*section
+
+func addSection(state *gostState, dir string, env genv.T) {
+	path := fpath.Join("/", strings.TrimPrefix(dir, state.srcDir))
+	s := &section{path: path, env: env, indexes: make(map[string]genv.T)}
+	if parent, ok := sections[fpath.Dir(path)]; ok && path != "/" {
+		s.parent = parent
+		parent.children = append(parent.children, s)
+	}
+	sections[path] = s
+}
+
+// adds the env of the itemplate in srcPath to its section
+func addToSection(state *gostState, srcPath string, env genv.T) {
+	relPath := strings.TrimPrefix(srcPath, state.srcDir)
+	s, ok := sections[fpath.Join("/", fpath.Dir(relPath))]
+	if !ok {
+		return
+	}
+	pageSections[env.Get("path")] = s
+	_, neutralPath := state.splitLang(relPath)
+	if base := fpath.Base(neutralPath); strings.TrimSuffix(base, fpath.Ext(base)) == "index" {
+		s.indexes[env.Get(langKey)] = env
+		return
+	}
+	s.pages = append(s.pages, env)
+}
+
+func (s *section) title(lang string) string {
+	// the root has no parent to inherit the title from
+	if s.parent == nil {
+		if title := s.env.Get("title"); title != "" {
+			return title
+		}
+	} else if title, ok := s.env.OwnEntries()["title"]; ok {
+		return toString(title)
+	}
+	if index, ok := s.indexes[lang]; ok && index.Get("title") != "" {
+		return index.Get("title")
+	}
+	if s.parent == nil {
+		return "home"
+	}
+	return fpath.Base(s.path)
+}
+
+// sectionView is the section as seen by the templates
+// of the pages in a language
+type sectionView struct {
+	s    *section
+	lang string
+}
+
+func (v sectionView) Path() string  { return v.s.path }
+func (v sectionView) Name() string  { return fpath.Base(v.s.path) }
+func (v sectionView) Title() string { return v.s.title(v.lang) }
+
+func (v sectionView) Env() map[string]interface{} {
+	return v.s.env.Entries()
+}
+
+// the env of the index page, nil if there is none
+func (v sectionView) Index() map[string]interface{} {
+	if index, ok := v.s.indexes[v.lang]; ok {
+		return index.Entries()
+	}
+	return nil
+}
+
+// the envs of the other itemplates, sorted by path
+func (v sectionView) Pages() []interface{} {
+	var envs []genv.T
+	for _, env := range v.s.pages {
+		if env.Get(langKey) == v.lang {
+			envs = append(envs, env)
+		}
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		return envs[i].Get("path") < envs[j].Get("path")
+	})
+	var result []interface{}
+	for _, env := range envs {
+		result = append(result, env.Entries())
+	}
+	return result
+}
+
+// tells whether the section has no itemplates, such as
+// the includes-dir whose files are excluded from the index
+func (s *section) isEmpty() bool {
+	if len(s.pages) > 0 || len(s.indexes) > 0 {
+		return false
+	}
+	for _, child := range s.children {
+		if !child.isEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// the subsections that are not empty, sorted by path
+func (v sectionView) Sections() []sectionView {
+	var views []sectionView
+	for _, child := range v.s.children {
+		if !child.isEmpty() {
+			views = append(views, sectionView{child, v.lang})
+		}
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].s.path < views[j].s.path
+	})
+	return views
+}
+
+// the parent section, nil for the root
+func (v sectionView) Parent() *sectionView {
+	if v.s.parent == nil {
+		return nil
+	}
+	return &sectionView{v.s.parent, v.lang}
+}
+
+// returns the title and path of each section from the root
+// to the page in env, followed by the page itself.
+// The path of a section is that of its index page,
+// empty if there is none.
+func breadcrumbs(env genv.T) []map[string]interface{} {
+	lang := env.Get(langKey)
+	path := env.Get("path")
+	var crumbs []map[string]interface{}
+	for s := pageSections[path]; s != nil; s = s.parent {
+		crumb := map[string]interface{}{"title": s.title(lang), "path": ""}
+		if index, ok := s.indexes[lang]; ok {
+			crumb["path"] = index.Get("path")
+		}
+		crumbs = append([]map[string]interface{}{crumb}, crumbs...)
+	}
+	if n := len(crumbs); n == 0 || crumbs[n-1]["path"] != path {
+		crumbs = append(crumbs, map[string]interface{}{"title": env.Get("title"), "path": path})
+	}
+	return crumbs
+}
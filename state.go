@@ -12,6 +12,13 @@ type gostState struct {
 	layoutsDir  string
 	protosDir   string
 	baseEnv     genv.T
+	out         *output
+	themes      []*theme
+	languages   []string
+
+	imageCacheDir string
+	hooks         map[string]string
+	jobs          int
 
 	verbatimList []predicate
 	excludeList  []predicate
@@ -21,6 +28,8 @@ func newState(srcDir, destDir string) *gostState {
 	return &gostState{
 		srcDir:  srcDir,
 		destDir: destDir,
+		out:     newOutput(false),
+		jobs:    1,
 	}
 }
 
@@ -39,6 +48,26 @@ func (state *gostState) setProtosDir(dir string) *gostState {
 	return state
 }
 
+func (state *gostState) setOutput(out *output) *gostState {
+	state.out = out
+	return state
+}
+
+func (state *gostState) setImageCacheDir(dir string) *gostState {
+	state.imageCacheDir = util.PrependPath(dir, state.srcDir)
+	return state
+}
+
+func (state *gostState) setJobs(n int) *gostState {
+	state.jobs = util.Max(n, 1)
+	return state
+}
+
+func (state *gostState) setThemes(themes []*theme) *gostState {
+	state.themes = themes
+	return state
+}
+
 func (state *gostState) setVerbatimList(preds []predicate) *gostState {
 	state.verbatimList = preds
 	return state
@@ -2,17 +2,22 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"github.com/nvlled/gost/genv"
+	"github.com/nvlled/gost/highlight"
+	"github.com/nvlled/gost/templfuncs"
 	"github.com/nvlled/gost/util"
+	fpath "path/filepath"
+	"sort"
+	"strings"
 	"text/template"
-	"time"
 )
 
 var globalFuncMap = template.FuncMap{
 	"genid": util.GenerateId,
 	"shell": util.Exec,
 	"date": func() string {
-		return time.Now().Format("Mon, 02 Jan 2006 MST")
+		return buildTime.In(timeZone).Format("Mon, 02 Jan 2006 MST")
 	},
 	"equals": func(x, y interface{}) bool {
 		yep := false
@@ -27,6 +32,12 @@ var globalFuncMap = template.FuncMap{
 		wah()
 		return yep
 	},
+	// lang is first so that code can be piped:
+	// {{.code | highlight "go"}}
+	"highlight": func(lang, code string) string {
+		return highlight.Highlight(code, lang)
+	},
+	"highlight_css": highlight.Stylesheet,
 	"when": func(cond bool, conseq string, alt ...string) string {
 		if cond {
 			return conseq
@@ -43,57 +54,167 @@ var globalFuncMap = template.FuncMap{
 	// They will subsequently be overriden
 	// by actual implementations created by createFuncMap()
 	// which are used by applyTemplate and applyLayout.
-	"url":      func(_ ...interface{}) interface{} { return "" },
-	"urlfor":   func(_ ...interface{}) interface{} { return "" },
-	"with_env": func(_ ...interface{}) interface{} { return "" },
+	"url":          func(_ ...interface{}) interface{} { return "" },
+	"urlfor":       func(_ ...interface{}) interface{} { return "" },
+	"absurl":       func(_ ...interface{}) interface{} { return "" },
+	"absurlfor":    func(_ ...interface{}) interface{} { return "" },
+	"with_env":     func(_ ...interface{}) interface{} { return "" },
+	"translations": func(_ ...interface{}) interface{} { return "" },
+	"image":        func(_ ...interface{}) interface{} { return "" },
+	"image_srcset": func(_ ...interface{}) interface{} { return "" },
+	"related":      func(_ ...interface{}) interface{} { return "" },
+	"prev":         func(_ ...interface{}) interface{} { return "" },
+	"next":         func(_ ...interface{}) interface{} { return "" },
+	"section":      func(_ ...interface{}) interface{} { return "" },
+	"breadcrumbs":  func(_ ...interface{}) interface{} { return "" },
 }
 
-func createFuncMap(curPath string, relativeUrl bool) template.FuncMap {
-	return template.FuncMap{
-		"url": func(path string) string {
-			if relativeUrl {
-				return util.RelativizePath(curPath, path)
-			}
+func createFuncMap(state *gostState, curEnv genv.T) template.FuncMap {
+	curPath := curEnv.Get("path")
+	curLang := curEnv.Get(langKey)
+	relativeUrl := isUrlRelative(curEnv)
+	base, baseErr := parseBaseURL(curEnv.Get(baseURLKey))
+	url := func(path string) string {
+		path = prettyPath(path)
+		if relativeUrl {
+			return util.RelativizePath(curPath, path)
+		}
+		return base.withPrefix(path)
+	}
+	absurl := func(path string) (string, error) {
+		if baseErr != nil {
+			return "", baseErr
+		}
+		return base.absolute(curPath, prettyPath(path))
+	}
+	// image paths are relative to the source of the current
+	// file unless they start with a slash
+	imagePath := func(path string) string {
+		if strings.HasPrefix(path, "/") {
 			return path
-		},
+		}
+		dir := fpath.Dir(curPath)
+		if src, ok := srcPaths[curPath]; ok {
+			dir = fpath.Dir(srcRelativePath(state, src))
+		}
+		return fpath.Join(dir, path)
+	}
+	funcMap := template.FuncMap{
+		"url": url,
 		"urlfor": func(id string) string {
-			if env, ok := index[id]; ok {
-				path := env.Get("path")
-				if relativeUrl {
-					return util.RelativizePath(curPath, path)
-				}
-				return path
+			if env, ok := lookupId(curLang, id); ok {
+				return url(env.Get("path"))
 			}
 			return "#nope"
 		},
+		"absurl": absurl,
+		"absurlfor": func(id string) (string, error) {
+			env, ok := lookupId(curLang, id)
+			if !ok {
+				return "", errors.New("absurlfor: unknown id " + id)
+			}
+			return absurl(env.Get("path"))
+		},
 		"with_env": func(key string, value interface{}) []interface{} {
-			var envs []interface{}
-			for _, env := range index {
+			var envs []genv.T
+			for _, env := range langIndex[curLang] {
 				v := env.Get(key)
 				if value == v {
-					envs = append(envs, env.Entries())
+					envs = append(envs, env)
 				}
 			}
+			// sorted so that the output is the same on every build
+			sort.Slice(envs, func(i, j int) bool {
+				return envs[i].Get("path") < envs[j].Get("path")
+			})
+			var result []interface{}
+			for _, env := range envs {
+				result = append(result, env.Entries())
+			}
+			return result
+		},
+		"translations": func() []interface{} {
+			return translationsOf(curEnv)
+		},
+		"image": func(path, spec string) (imageInfo, error) {
+			img, err := processImage(state, imagePath(path), spec)
+			img.URL = url(img.Path)
+			return img, err
+		},
+		"image_srcset": func(path, widths string, spec ...string) (string, error) {
+			return imageSrcset(state, imagePath(path), widths, strings.Join(spec, " "), url)
+		},
+		"related": func(n int, keys ...string) []interface{} {
+			var envs []interface{}
+			for _, env := range relatedPages(curEnv, n, keys) {
+				envs = append(envs, env.Entries())
+			}
 			return envs
 		},
+		"prev": func(query, sortKey string) (map[string]interface{}, error) {
+			env, err := neighbor(state, curEnv, query, sortKey, -1)
+			if env == nil {
+				return nil, err
+			}
+			return env.Entries(), nil
+		},
+		"next": func(query, sortKey string) (map[string]interface{}, error) {
+			env, err := neighbor(state, curEnv, query, sortKey, 1)
+			if env == nil {
+				return nil, err
+			}
+			return env.Entries(), nil
+		},
+		"section": func(path ...string) *sectionView {
+			s, ok := pageSections[curPath]
+			if len(path) > 0 {
+				s, ok = sections[fpath.Join("/", path[0])]
+			}
+			if !ok {
+				return nil
+			}
+			return &sectionView{s, curLang}
+		},
+		"breadcrumbs": func() []map[string]interface{} {
+			return breadcrumbs(curEnv)
+		},
+	}
+
+	// registered functions replace the ones above
+	for _, name := range templfuncs.Names() {
+		delete(funcMap, name)
+	}
+	page := templfuncs.Page{Env: curEnv, Index: langIndex[curLang], Pages: pathIndex}
+	for name, fn := range templfuncs.ForPage(page) {
+		funcMap[name] = fn
+	}
+	return funcMap
+}
+
+// finds the env with the id, preferably in the language
+func lookupId(lang, id string) (genv.T, bool) {
+	env, ok := langIndex[lang][id]
+	if !ok {
+		env, ok = index[id]
 	}
+	return env, ok
 }
 
 func createTemplate() *template.Template {
-	return template.New("default").Funcs(globalFuncMap)
+	return template.New("default").Funcs(stdFuncMap).Funcs(dateFuncMap).Funcs(globalFuncMap).Funcs(templfuncs.Global())
 }
 
-func applyTemplate(t *template.Template, s string, env genv.T) string {
+func applyTemplate(state *gostState, t *template.Template, s string, env genv.T) string {
 	curPath := env.Get("path")
 	buf := new(bytes.Buffer)
-	funcs := createFuncMap(curPath, isUrlRelative(env))
+	funcs := createFuncMap(state, env)
 	entries := env.Entries()
 	err := template.Must(t.New(curPath).Funcs(funcs).Parse(s)).Execute(buf, entries)
 	fail(err)
 	return buf.String()
 }
 
-func applyLayout(t *template.Template, s string, env genv.T) string {
+func applyLayout(state *gostState, t *template.Template, s string, env genv.T) string {
 	layout := env.Get(layoutKey)
 	if layout == "" {
 		return s
@@ -106,16 +227,44 @@ func applyLayout(t *template.Template, s string, env genv.T) string {
 
 	curPath := env.Get("path")
 	buf := new(bytes.Buffer)
-	funcs := createFuncMap(curPath, isUrlRelative(env))
+	funcs := createFuncMap(state, env)
 	entries := env.Entries()
 	err := t.New(curPath).Funcs(funcs).ExecuteTemplate(buf, layout, entries)
 	fail(err)
 	return buf.String()
 }
 
-func isUrlRelative(env genv.T) bool {
-	if v, ok := env.GetOk(relativeKey); ok {
+// renders the contents of an itemplate. Html files are
+// post-processed and then applied to their layout.
+func renderItemplate(state *gostState, t *template.Template, path, s string, env genv.T) string {
+	s = applyTemplate(state, t, s, env)
+	if fpath.Ext(path) == ".html" {
+		s = postRender(s, env)
+		renderedContents[env.Get("path")] = s
+		s = applyLayout(state, t, s, env)
+	}
+	return s
+}
+
+// processes the rendered html before it is applied to the layout
+func postRender(s string, env genv.T) string {
+	if isEnabled(env, highlightKey) {
+		s = highlight.HighlightHTML(s)
+	}
+	if tocDepth(env) > 0 {
+		s = addHeadingAnchors(s, env)
+	}
+	return s
+}
+
+// entries for options are enabled unless set to false or 0
+func isEnabled(env genv.T, key string) bool {
+	if v, ok := env.GetOk(key); ok {
 		return !(v == "false" || v == "0")
 	}
 	return true
 }
+
+func isUrlRelative(env genv.T) bool {
+	return isEnabled(env, relativeKey)
+}
@@ -0,0 +1,99 @@
+// Package templfuncs lets programs that build gost add their
+// own template functions. Functions are registered from an
+// init function, in a file added to the gost sources or in
+// a package imported by one. gost has no entry point that a
+// separate wrapper binary could call after registering:
+//
+//	func init() {
+//		templfuncs.Register("shout", strings.ToUpper)
+//		templfuncs.RegisterPage("siblings", func(page templfuncs.Page) interface{} {
+//			return func() int { return len(page.Index) }
+//		})
+//	}
+package templfuncs
+
+import (
+	"fmt"
+	"github.com/nvlled/gost/genv"
+	"reflect"
+	"sort"
+	"text/template"
+)
+
+// Page is what per-page functions know about
+// the page being rendered
+type Page struct {
+	// the env of the page
+	Env genv.T
+	// the indexed pages in the language of the page, by id
+	Index map[string]genv.T
+	// all the itemplates, by path in the srcDir
+	Pages map[string]genv.T
+}
+
+// PageFunc creates the function for a page
+type PageFunc func(page Page) interface{}
+
+var (
+	globalFuncs = template.FuncMap{}
+	pageFuncs   = map[string]PageFunc{}
+)
+
+// Register adds a function that is available in all templates.
+// The function must be valid for text/template, that is,
+// it must return a value, and optionally an error.
+// It panics if fn is not a function.
+func Register(name string, fn interface{}) {
+	if reflect.ValueOf(fn).Kind() != reflect.Func {
+		panic(fmt.Sprintf("templfuncs: %s is not a function", name))
+	}
+	globalFuncs[name] = fn
+	delete(pageFuncs, name)
+}
+
+// RegisterPage adds a function that is created for each page
+// by newFunc, which returns a function as in Register.
+func RegisterPage(name string, newFunc PageFunc) {
+	pageFuncs[name] = newFunc
+	delete(globalFuncs, name)
+}
+
+// Names returns the names of the registered functions
+func Names() []string {
+	var names []string
+	for name := range globalFuncs {
+		names = append(names, name)
+	}
+	for name := range pageFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Global returns the functions added by Register, and stubs
+// for the functions added by RegisterPage so that templates
+// that use them can be parsed before the pages are rendered.
+func Global() template.FuncMap {
+	funcMap := template.FuncMap{}
+	for name := range pageFuncs {
+		funcMap[name] = func(_ ...interface{}) interface{} { return "" }
+	}
+	for name, fn := range globalFuncs {
+		funcMap[name] = fn
+	}
+	return funcMap
+}
+
+// ForPage returns the functions added by RegisterPage for the page
+func ForPage(page Page) template.FuncMap {
+	funcMap := template.FuncMap{}
+	for name, newFunc := range pageFuncs {
+		fn := newFunc(page)
+		if reflect.ValueOf(fn).Kind() != reflect.Func {
+			panic(fmt.Sprintf("templfuncs: %s did not return a function", name))
+		}
+		funcMap[name] = fn
+	}
+	return funcMap
+}
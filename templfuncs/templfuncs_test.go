@@ -0,0 +1,34 @@
+package templfuncs
+
+import (
+	"bytes"
+	"github.com/nvlled/gost/genv"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestRegister(t *testing.T) {
+	Register("shout", strings.ToUpper)
+	RegisterPage("title", func(page Page) interface{} {
+		return func() string { return page.Env.Get("title") }
+	})
+
+	// templates are parsed with the stubs,
+	// then executed with the page functions
+	tmpl, err := template.New("").Funcs(Global()).Parse(`{{title | shout}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := genv.New()
+	env.Set("title", "hello")
+	tmpl.Funcs(ForPage(Page{Env: env}))
+
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "HELLO" {
+		t.Error("Expected HELLO got", buf.String())
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	"github.com/nvlled/gost/util"
+	"os"
+	fpath "path/filepath"
+	"strings"
+	"text/template"
+)
+
+// A theme is a directory laid out like a srcDir.
+// Layouts, includes, protos and static files are taken
+// from the theme when the site doesn't have them.
+// A theme may have a theme entry in its env to
+// use another theme as a fallback.
+type theme struct {
+	dir         string
+	includesDir string
+	layoutsDir  string
+	protosDir   string
+}
+
+func newTheme(dir string) (*theme, genv.T) {
+	env := genv.ReadDir(dir)
+	return &theme{
+		dir:         dir,
+		includesDir: util.PrependPath(env.GetOr(includesKey, defaultIncludesDir), dir),
+		layoutsDir:  util.PrependPath(env.GetOr(layoutsKey, defaultLayoutsDir), dir),
+		protosDir:   util.PrependPath(env.GetOr(protosKey, defaultProtosDir), dir),
+	}, env
+}
+
+// returns the theme in dir followed by the themes it uses
+func loadThemes(dir string) []*theme {
+	var themes []*theme
+	seen := make(map[string]bool)
+	for dir != "" && !seen[dir] {
+		seen[dir] = true
+		if !util.DirExists(dir) {
+			println("** theme not found:", dir)
+			break
+		}
+		t, env := newTheme(dir)
+		themes = append(themes, t)
+		printLog("using theme", dir)
+
+		next := env.Get(themeKey)
+		if next == "" {
+			break
+		}
+		dir = util.PrependPath(next, dir)
+	}
+	return themes
+}
+
+func (t *theme) templateDir(key string) string {
+	switch key {
+	case includesKey:
+		return t.includesDir
+	case layoutsKey:
+		return t.layoutsDir
+	case protoKey:
+		return t.protosDir
+	}
+	return ""
+}
+
+// static files of the theme are all files except
+// for dot files, env files and template files
+func (t *theme) isFileExcluded(path string) bool {
+	if isDotFile(NoVars, path) || fpath.Base(path) == genv.FILENAME {
+		return true
+	}
+	for _, dir := range []string{t.includesDir, t.layoutsDir, t.protosDir} {
+		if path == dir || strings.HasPrefix(path, dir+string(fpath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the dirs of the templates for key,
+// from the last theme to the srcDir, so that
+// templates in the srcDir are defined last
+func (state *gostState) templateDirs(key string) []string {
+	var dirs []string
+	for i := len(state.themes) - 1; i >= 0; i-- {
+		dirs = append(dirs, state.themes[i].templateDir(key))
+	}
+	switch key {
+	case includesKey:
+		dirs = append(dirs, state.includesDir)
+	case layoutsKey:
+		dirs = append(dirs, state.layoutsDir)
+	case protoKey:
+		dirs = append(dirs, state.protosDir)
+	}
+	return dirs
+}
+
+// templates with the same name from a later dir
+// replace the ones from the previous dirs
+func globThemeTemplates(t *template.Template, state *gostState, key string) {
+	dirs := state.templateDirs(key)
+	for i, dir := range dirs {
+		// missing dirs are only reported for the srcDir
+		if i == len(dirs)-1 || util.DirExists(dir) {
+			printLog("loading", key, dir)
+			globTemplates(t, key, dir)
+		}
+	}
+}
+
+// copies the static files of the themes that
+// are not yet in the destDir. written contains the
+// paths, relative to destDir, that are already built.
+func copyThemeFiles(state *gostState, written map[string]bool) {
+	out := state.out
+	for _, t := range state.themes {
+		t := t
+		fpath.Walk(t.dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || t.isFileExcluded(path) {
+				return nil
+			}
+			rel := strings.TrimPrefix(path, util.AddTrailingSlash(t.dir))
+			if written[rel] || state.isFileExcluded(rel) {
+				return nil
+			}
+			written[rel] = true
+
+			destPath := fpath.Join(state.destDir, rel)
+			out.mkdir(fpath.Dir(destPath))
+			return out.copyFile(path, destPath)
+		})
+	}
+}
+
+// tells whether path, relative to srcDir, is
+// in the srcDir or in one of the themes
+func (state *gostState) sourceExists(path string) bool {
+	dirs := []string{state.srcDir}
+	for _, t := range state.themes {
+		dirs = append(dirs, t.dir)
+	}
+	for _, dir := range dirs {
+		if _, err := os.Lstat(fpath.Join(dir, path)); err == nil {
+			return true
+		}
+	}
+	return false
+}
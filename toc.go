@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"github.com/nvlled/gost/genv"
+	"github.com/nvlled/gost/util"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Headings from h2 down to h(toc-depth + 1) are given ids
+// from their text, and are listed in the table of contents
+// which is available to the layout as {{.toc}}.
+
+const defaultTocDepth = 3
+
+var headingRe = regexp.MustCompile(`(?is)<h([2-6])(\s[^>]*)?>(.*?)</h[2-6]>`)
+var idAttrRe = regexp.MustCompile(`(?i)\bid\s*=\s*["']([^"']*)["']`)
+var tagRe = regexp.MustCompile(`<[^>]*>`)
+var tagIdRe = regexp.MustCompile(`(?i)<[^>]*?\sid\s*=\s*["']([^"']*)["']`)
+
+type heading struct {
+	level int
+	id    string
+	text  string
+}
+
+func tocDepth(env genv.T) int {
+	depth, err := strconv.Atoi(env.Get(tocDepthKey))
+	if err != nil {
+		return defaultTocDepth
+	}
+	return depth
+}
+
+// adds ids to the headings of s, and sets
+// the toc entry of env if there are any headings
+func addHeadingAnchors(s string, env genv.T) string {
+	maxLevel := 1 + tocDepth(env)
+	var headings []heading
+
+	// ids of the document, including the ones of the
+	// other elements, are not given to the headings
+	seen := make(map[string]bool)
+	for _, m := range tagIdRe.FindAllStringSubmatch(s, -1) {
+		seen[m[1]] = true
+	}
+	uniqueId := func(slug string) string {
+		id := slug
+		for n := 2; seen[id]; n++ {
+			id = fmt.Sprintf("%s-%d", slug, n)
+		}
+		seen[id] = true
+		return id
+	}
+
+	s = headingRe.ReplaceAllStringFunc(s, func(h string) string {
+		m := headingRe.FindStringSubmatch(h)
+		level, _ := strconv.Atoi(m[1])
+		attrs, inner := m[2], m[3]
+		if level > maxLevel {
+			return h
+		}
+		text := strings.TrimSpace(html.UnescapeString(tagRe.ReplaceAllString(inner, "")))
+
+		if idm := idAttrRe.FindStringSubmatch(attrs); idm != nil {
+			headings = append(headings, heading{level, idm[1], text})
+			return h
+		}
+		id := util.Slugify(text)
+		if id == "" {
+			id = "section"
+		}
+		id = uniqueId(id)
+		headings = append(headings, heading{level, id, text})
+		return fmt.Sprintf(`<h%d id="%s"%s>%s</h%d>`, level, id, attrs, inner, level)
+	})
+
+	if len(headings) > 0 {
+		env.Set("toc", renderToc(headings))
+	}
+	return s
+}
+
+// lists the headings in nested lists
+func renderToc(headings []heading) string {
+	var buf strings.Builder
+	buf.WriteString(`<ul class="toc">`)
+	// levels of the open lists
+	stack := []int{headings[0].level}
+	top := func() int { return stack[len(stack)-1] }
+
+	for i, h := range headings {
+		if i > 0 && h.level > top() {
+			buf.WriteString("<ul>")
+			stack = append(stack, h.level)
+		} else if i > 0 {
+			buf.WriteString("</li>")
+			for len(stack) > 1 && h.level < top() && h.level <= stack[len(stack)-2] {
+				buf.WriteString("</ul></li>")
+				stack = stack[:len(stack)-1]
+			}
+		}
+		fmt.Fprintf(&buf, `<li><a href="#%s">%s</a>`, html.EscapeString(h.id), html.EscapeString(h.text))
+	}
+	buf.WriteString("</li>")
+	for len(stack) > 1 {
+		buf.WriteString("</ul></li>")
+		stack = stack[:len(stack)-1]
+	}
+	buf.WriteString("</ul>")
+	return buf.String()
+}
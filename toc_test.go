@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	"testing"
+)
+
+func TestHeadingAnchors(t *testing.T) {
+	testData := [][]string{
+		// html  expected html
+		{
+			"<h2>Intro</h2><h2>Intro</h2><h2>Intro 2</h2>",
+			`<h2 id="intro">Intro</h2><h2 id="intro-2">Intro</h2><h2 id="intro-2-2">Intro 2</h2>`,
+		},
+		{
+			`<p id="intro">x</p><h2>Intro</h2>`,
+			`<p id="intro">x</p><h2 id="intro-2">Intro</h2>`,
+		},
+		{
+			`<h2>Setup</h2><h3 id="setup-2">More</h3><h2>Setup</h2>`,
+			`<h2 id="setup">Setup</h2><h3 id="setup-2">More</h3><h2 id="setup-3">Setup</h2>`,
+		},
+	}
+	for _, row := range testData {
+		if result := addHeadingAnchors(row[0], genv.New()); result != row[1] {
+			t.Error("html =", row[0], "| Expected", row[1], "got", result)
+		}
+	}
+}
+
+func TestRenderToc(t *testing.T) {
+	type rowt struct {
+		levels   []int
+		expected string
+	}
+	testData := []rowt{
+		{[]int{2, 2}, `<ul class="toc"><li><a href="#a">a</a></li><li><a href="#b">b</a></li></ul>`},
+		{[]int{2, 3, 3, 2}, `<ul class="toc"><li><a href="#a">a</a><ul><li><a href="#b">b</a></li><li><a href="#c">c</a></li></ul></li><li><a href="#d">d</a></li></ul>`},
+		// skipped levels are nested once
+		{[]int{2, 4, 2}, `<ul class="toc"><li><a href="#a">a</a><ul><li><a href="#b">b</a></li></ul></li><li><a href="#c">c</a></li></ul>`},
+		{[]int{2, 3, 4, 2}, `<ul class="toc"><li><a href="#a">a</a><ul><li><a href="#b">b</a><ul><li><a href="#c">c</a></li></ul></li></ul></li><li><a href="#d">d</a></li></ul>`},
+		// starting below the first level
+		{[]int{3, 2}, `<ul class="toc"><li><a href="#a">a</a></li><li><a href="#b">b</a></li></ul>`},
+	}
+	for _, row := range testData {
+		var headings []heading
+		for i, level := range row.levels {
+			id := string('a' + rune(i))
+			headings = append(headings, heading{level, id, id})
+		}
+		if result := renderToc(headings); result != row.expected {
+			t.Error("levels =", row.levels, "| Expected", row.expected, "got", result)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"github.com/nvlled/gost/genv"
+	"github.com/nvlled/gost/util"
+	"net/url"
+	fpath "path/filepath"
+	"strings"
+)
+
+// The base-url entry is the url where the site is hosted,
+// such as https://example.com/docs/. Its path is the prefix
+// of the root-relative urls of the site.
+
+type baseURL struct {
+	url    *url.URL
+	prefix string // e.g. /docs, empty for the root
+}
+
+func parseBaseURL(s string) (baseURL, error) {
+	if s == "" {
+		return baseURL{}, nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return baseURL{}, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return baseURL{}, errors.New("base-url must be an absolute url: " + s)
+	}
+	return baseURL{u, strings.TrimSuffix(u.Path, "/")}, nil
+}
+
+// urls with a scheme, such as mailto:x or https://x,
+// and protocol-relative urls (//host/x) are left as is
+func isExternalURL(s string) bool {
+	if strings.HasPrefix(s, "//") {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != ""
+}
+
+// adds the prefix of the base-url to root-relative paths
+func (base baseURL) withPrefix(path string) string {
+	if base.prefix == "" || !strings.HasPrefix(path, "/") || isExternalURL(path) {
+		return path
+	}
+	return base.prefix + path
+}
+
+// returns the absolute url of path, which is relative
+// to the dir of curPath unless it starts with a slash
+func (base baseURL) absolute(curPath, path string) (string, error) {
+	if isExternalURL(path) {
+		return path, nil
+	}
+	if base.url == nil {
+		return "", errors.New("absurl requires a base-url entry")
+	}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(ref.Path, "/") {
+		ref.Path = fpath.Join(fpath.Dir(curPath), ref.Path)
+	}
+	u := *base.url
+	u.Path = base.prefix + ref.Path
+	u.RawPath = ""
+	u.RawQuery = ref.RawQuery
+	u.Fragment = ref.Fragment
+	return u.String(), nil
+}
+
+// With pretty-urls: true, html pages are built as the index.html
+// of a directory so that their urls don't have the extension:
+// /about.html is built as /about/index.html and its path is /about/.
+func pagePath(env genv.T, path string) string {
+	if !toBool(env.Get(prettyURLsKey)) || fpath.Ext(path) != ".html" {
+		return path
+	}
+	dir, base := fpath.Split(path)
+	if base != "index.html" {
+		dir = fpath.Join(dir, strings.TrimSuffix(base, ".html"))
+	}
+	return util.AddTrailingSlash(dir)
+}
+
+// source files of the pages by path of the env, since the
+// path of a page may be in a different directory than its source
+var srcPaths map[string]string
+
+// source files of the pages by output file, relative to destDir,
+// to detect pages that would be built as the same file
+var pageFiles map[string]string
+
+// pretty paths of the pages by the paths they would have
+// without pretty-urls, so that url maps /about.html to /about/
+var prettyPaths map[string]string
+
+// sets the path entry of env to the page path of path
+func setPagePath(env genv.T, path string) {
+	if p := pagePath(env, path); p != path {
+		prettyPaths[path] = p
+		path = p
+	}
+	env.Set("path", path)
+}
+
+// returns the pretty path of a page given by its path without
+// pretty-urls, keeping the query and fragment
+func prettyPath(path string) string {
+	i := strings.IndexAny(path, "?#")
+	if i < 0 {
+		i = len(path)
+	}
+	if p, ok := prettyPaths[path[:i]]; ok {
+		return p + path[i:]
+	}
+	return path
+}
+
+// returns the file of a page path, relative to destDir
+func pageFile(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return path + "index.html"
+	}
+	return path
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/nvlled/gost/genv"
+	"testing"
+)
+
+func TestBaseURL(t *testing.T) {
+	base, err := parseBaseURL("https://example.com/docs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testData := [][]string{
+		// curPath path url absurl
+		{"/a/b.html", "/c.html", "/docs/c.html", "https://example.com/docs/c.html"},
+		{"/a/b.html", "c.html#x", "c.html#x", "https://example.com/docs/a/c.html#x"},
+		{"/a/b.html", "https://other.org/", "https://other.org/", "https://other.org/"},
+		{"/a/b.html", "/feed.xml?v=1", "/docs/feed.xml?v=1", "https://example.com/docs/feed.xml?v=1"},
+	}
+	for _, row := range testData {
+		if result := base.withPrefix(row[1]); result != row[2] {
+			t.Error("path =", row[1], "| Expected", row[2], "got", result)
+		}
+		if result, err := base.absolute(row[0], row[1]); err != nil || result != row[3] {
+			t.Error("path =", row[1], "| Expected", row[3], "got", result, err)
+		}
+	}
+	if _, err := parseBaseURL("example.com"); err == nil {
+		t.Error("Expected an error for a url without a scheme")
+	}
+}
+
+func TestPrettyPaths(t *testing.T) {
+	resetIndex()
+	defer resetIndex()
+	for _, path := range []string{"/about.html", "/docs/index.html", "/feed.xml"} {
+		setPagePath(genv.Parse("pretty-urls: true"), path)
+	}
+	env := genv.Parse("path: /index.html\nbase-url: https://example.com/")
+	absurl := createFuncMap(newState("", ""), env)["absurl"].(func(string) (string, error))
+	testData := [][]string{
+		// path  pretty path  absurl
+		{"/about.html", "/about/", "https://example.com/about/"},
+		{"/about.html#team", "/about/#team", "https://example.com/about/#team"},
+		{"/docs/index.html?q=1", "/docs/?q=1", "https://example.com/docs/?q=1"},
+		{"/feed.xml", "/feed.xml", "https://example.com/feed.xml"},
+	}
+	for _, row := range testData {
+		if result := prettyPath(row[0]); result != row[1] {
+			t.Error("path =", row[0], "| Expected", row[1], "got", result)
+		}
+		if result, err := absurl(row[0]); err != nil || result != row[2] {
+			t.Error("path =", row[0], "| Expected", row[2], "got", result, err)
+		}
+	}
+}
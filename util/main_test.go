@@ -38,3 +38,37 @@ func TestDirLevel(t *testing.T) {
 		}
 	}
 }
+
+func TestSlugify(t *testing.T) {
+	testData := [][]string{
+		// input expected
+		{"Intro & Setup", "intro-setup"},
+		{"  Hello, World!  ", "hello-world"},
+		{"日本語 テキスト", "日本語-テキスト"},
+		{"---", ""},
+	}
+	for _, row := range testData {
+		result := Slugify(row[0])
+		if result != row[1] {
+			t.Error("input =", row[0], "| Expected", row[1], "got", result)
+		}
+	}
+}
+
+func TestRelativizePath(t *testing.T) {
+	testData := [][]string{
+		// srcPath destPath expected
+		{"/articles/x.html", "/articles/y.html", "y.html"},
+		{"/articles/x.html", "/index.html", "../index.html"},
+		{"/articles/x.html", "/articles", "../articles"},
+		{"/", "/styles/site.css", "styles/site.css"},
+		{"/about/", "/styles/site.css", "../styles/site.css"},
+		{"/about/", "/about/photo.jpg", "photo.jpg"},
+		{"/about/", "/", "../"},
+		{"/articles/x/", "/articles/y/", "../y/"},
+		{"/index.html", "/about/", "about/"},
+		{"/about/", "/about/", "./"},
+		{"/a.html", "https://x.org/", "https://x.org/"},
+	}
+	testutil.TestStringOp2(t, testData, RelativizePath)
+}
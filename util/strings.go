@@ -56,38 +56,33 @@ func RandomString() string {
 	return strconv.FormatInt(rand.Int63(), 36)
 }
 
+// RelativizePath returns destPath relative to the page in srcPath.
+// Paths ending with a slash are directories, such as /about/
+// for /about/index.html, and stay directories.
 func RelativizePath(srcPath, destPath string) string {
-	re := regexp.MustCompile(`^/`)
 	if srcPath == "/" {
 		if destPath == "/" {
 			return "."
 		}
 		return strings.TrimPrefix(destPath, "/")
 	}
-	if !re.MatchString(destPath) {
+	if !strings.HasPrefix(destPath, "/") {
 		return destPath
 	}
-	if !re.MatchString(srcPath) {
-		srcPath = fpath.Join("/", srcPath)
+	if !strings.HasPrefix(srcPath, "/") {
+		srcPath = "/" + srcPath
 	}
-
-	sep := string(fpath.Separator)
-	prefix := CommonSubPath(destPath, srcPath) + sep
-
-	srcPath_ := strings.TrimPrefix(srcPath, prefix)
-	destPath_ := strings.TrimPrefix(destPath, prefix)
-
-	slevel := DirLevel(srcPath_) - 1
-
-	if slevel > 0 {
-		paths := Times("..", slevel)
-		paths = append(paths, destPath_)
-		return fpath.Join(paths...)
+	srcDir := srcPath
+	if !strings.HasSuffix(srcDir, "/") {
+		srcDir = path.Dir(srcDir)
 	}
-	if destPath_ == "/" {
-		return "."
+	if strings.HasSuffix(destPath, "/") {
+		rel, _ := fpath.Rel(srcDir, destPath)
+		return rel + "/"
 	}
-	return strings.TrimPrefix(destPath_, "/")
+	// relative to the parent so that /a from /a/b.html is ../a, not .
+	rel, _ := fpath.Rel(srcDir, path.Dir(destPath))
+	return fpath.Join(rel, path.Base(destPath))
 }
 
 func PrependPath(s, prefix string) string {
@@ -119,3 +114,12 @@ func SubDirList(baseDir string, path string) []string {
 	}
 	return result
 }
+
+var nonSlugRe = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// converts s into a lowercase string with only
+// letters, digits and dashes, for use in urls and ids
+func Slugify(s string) string {
+	s = nonSlugRe.ReplaceAllString(strings.ToLower(s), "-")
+	return strings.Trim(s, "-")
+}